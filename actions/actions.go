@@ -12,4 +12,5 @@ var (
 	colorRed      = string([]byte{27, 91, 57, 55, 59, 51, 49, 59, 49, 109})
 	colorReset    = string([]byte{27, 91, 48, 109})
 	notifications = false
+	verbose       = false
 )
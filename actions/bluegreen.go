@@ -0,0 +1,68 @@
+package actions
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"sync"
+
+	"github.com/n3integration/reload/runtime"
+)
+
+// blueGreen alternates the app between two ports, starting each generation
+// next to the running one and switching the proxy over once it is ready
+type blueGreen struct {
+	mu      sync.Mutex
+	runner  runtime.Runner
+	proxy   runtime.Proxy
+	to      *url.URL
+	ports   [2]int
+	active  int
+	started bool
+}
+
+func newBlueGreen(runner runtime.Runner, proxy runtime.Proxy, config *runtime.Config) (*blueGreen, error) {
+	to, err := url.Parse(config.ProxyTo)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first generation listens on the app port
+	return &blueGreen{
+		runner: runner,
+		proxy:  proxy,
+		to:     to,
+		ports:  [2]int{config.AppPort, config.AltPort},
+		active: 1,
+	}, nil
+}
+
+// replace starts the app on the idle port and switches to it once it is
+// ready, leaving the running app in place if it fails to start or ctx is
+// cancelled first
+func (b *blueGreen) replace(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	next := 1 - b.active
+	to := *b.to
+	to.Host = net.JoinHostPort(b.to.Hostname(), strconv.Itoa(b.ports[next]))
+
+	err := b.runner.Replace(b.ports[next], func(command *exec.Cmd, done <-chan struct{}) error {
+		return b.proxy.Swap(ctx, &to, command, done)
+	})
+	// when the first generation fails, the app is started on demand on the
+	// app port
+	if err == nil || !b.started {
+		b.active = next
+	}
+	b.started = true
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Switched to the app on port %d\n", b.ports[next])
+	return nil
+}
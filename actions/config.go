@@ -0,0 +1,206 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/n3integration/reload/runtime"
+)
+
+// ConfigCheck validates the configuration and prints it as resolved from the
+// command line, the environment and the project configuration file
+func ConfigCheck(c *cli.Context) {
+	config, path, err := loadConfig(c)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.SetPrefix(fmt.Sprintf("[%s] ", config.LogPrefix))
+
+	if path != "" {
+		logger.Printf("Using configuration from %s\n", path)
+	} else {
+		logger.Printf("No %s found, using flags and defaults\n", runtime.ConfigFile)
+	}
+
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		logger.Fatal(err)
+	}
+	fmt.Println(string(out))
+
+	if err := config.Validate(); err != nil {
+		logger.Fatal(err)
+	}
+	logger.Println("Configuration is valid")
+}
+
+// loadConfig merges the settings of the command line, the environment and
+// the project configuration file. Flags and environment variables take
+// precedence over the file, which takes precedence over the flag defaults.
+// The path of the file is returned when one is used.
+func loadConfig(c *cli.Context) (*runtime.Config, string, error) {
+	config := new(runtime.Config)
+	if err := applyFlags(c, config, true); err != nil {
+		return nil, "", err
+	}
+
+	path := c.GlobalString("config")
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, "", err
+		}
+		path, _ = runtime.FindConfig(wd)
+	}
+
+	if path != "" {
+		if err := config.Merge(path); err != nil {
+			return nil, "", err
+		}
+		if err := applyFlags(c, config, false); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if config.Build == "" {
+		config.Build = config.Path
+	}
+	if config.ProxyTo == "" {
+		config.ProxyTo = "http://localhost:" + strconv.Itoa(config.AppPort)
+	}
+	if config.BlueGreen && config.AltPort == 0 {
+		config.AltPort = config.AppPort + 1
+	}
+
+	return config, path, nil
+}
+
+// applyFlags copies the flags into config; unless all is set, only the flags
+// given on the command line or through the environment are copied
+func applyFlags(c *cli.Context, config *runtime.Config, all bool) error {
+	set := func(name string) bool {
+		return all || c.GlobalIsSet(name)
+	}
+	duration := func(name string) runtime.Duration {
+		return runtime.Duration(c.GlobalDuration(name))
+	}
+
+	if set("laddr") {
+		config.Laddr = c.GlobalString("laddr")
+	}
+	if set("port") {
+		config.Port = c.GlobalInt("port")
+	}
+	if set("appPort") {
+		config.AppPort = c.GlobalInt("appPort")
+	}
+	if set("blueGreen") {
+		config.BlueGreen = c.GlobalBool("blueGreen")
+	}
+	if set("altPort") {
+		config.AltPort = c.GlobalInt("altPort")
+	}
+	if set("socketActivation") {
+		config.SocketActivation = c.GlobalBool("socketActivation")
+	}
+	if set("h2c") {
+		config.H2C = c.GlobalBool("h2c")
+	}
+	if set("bin") {
+		config.Bin = c.GlobalString("bin")
+	}
+	if set("path") {
+		config.Path = c.GlobalString("path")
+	}
+	if set("build") {
+		config.Build = c.GlobalString("build")
+	}
+	if set("buildArgs") {
+		config.BuildArgs = c.GlobalString("buildArgs")
+	}
+	if set("keyFile") {
+		config.KeyFile = c.GlobalString("keyFile")
+	}
+	if set("certFile") {
+		config.CertFile = c.GlobalString("certFile")
+	}
+	if set("tls") {
+		config.TLS = c.GlobalBool("tls")
+	}
+	if set("immediate") {
+		config.Immediate = c.GlobalBool("immediate")
+	}
+	if set("buildWait") {
+		config.BuildWait = duration("buildWait")
+	}
+	if set("liveReload") {
+		config.LiveReload = c.GlobalBool("liveReload")
+	}
+	if set("readyTimeout") {
+		config.ReadyTimeout = duration("readyTimeout")
+	}
+	if set("healthPath") {
+		config.HealthPath = c.GlobalString("healthPath")
+	}
+	if set("stopSignal") {
+		config.StopSignal = c.GlobalString("stopSignal")
+	}
+	if set("stopTimeout") {
+		config.StopTimeout = duration("stopTimeout")
+	}
+	if set("preStop") {
+		config.PreStop = c.GlobalString("preStop")
+	}
+	if set("editor") {
+		config.Editor = c.GlobalString("editor")
+	}
+	if set("include") {
+		config.Include = c.GlobalStringSlice("include")
+	}
+	if set("exclude") {
+		config.Exclude = c.GlobalStringSlice("exclude")
+	}
+	if set("excludeDir") {
+		config.ExcludeDirs = c.GlobalStringSlice("excludeDir")
+	}
+	if set("all") {
+		config.All = c.GlobalBool("all")
+	}
+	if set("gitignore") {
+		config.GitIgnore = c.GlobalBool("gitignore")
+	}
+	if set("watcher") {
+		config.Watcher = c.GlobalString("watcher")
+	}
+	if set("pollInterval") {
+		config.PollInterval = duration("pollInterval")
+	}
+	if set("debounce") {
+		config.Debounce = duration("debounce")
+	}
+	if set("rule") {
+		config.Rules = nil
+		for _, value := range c.GlobalStringSlice("rule") {
+			rule, err := runtime.ParseActionRule(value)
+			if err != nil {
+				return err
+			}
+			config.Rules = append(config.Rules, rule)
+		}
+	}
+	if set("logPrefix") {
+		config.LogPrefix = c.GlobalString("logPrefix")
+	}
+	if set("notifications") {
+		config.Notifications = c.GlobalBool("notifications")
+	}
+	if set("verbose") {
+		config.Verbose = c.GlobalBool("verbose")
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/n3integration/reload/runtime"
+)
+
+// dependencies holds the latest dependency graph of the build, refreshed
+// after changes to the modules and after every build that was not cancelled
+type dependencies struct {
+	mu        sync.Mutex
+	dir       string
+	buildArgs []string
+	deps      *runtime.Deps
+	watcher   runtime.Watcher
+	embedded  map[string]bool
+	// watching serializes refreshing the watched modules
+	watching sync.Mutex
+}
+
+func newDependencies(dir string, buildArgs []string, watcher runtime.Watcher) *dependencies {
+	return &dependencies{dir: dir, buildArgs: buildArgs, watcher: watcher, embedded: make(map[string]bool)}
+}
+
+// load lists the dependencies of the build again
+func (d *dependencies) load() (*runtime.Deps, error) {
+	deps, err := runtime.LoadDeps(context.Background(), d.dir, d.buildArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []string
+	d.mu.Lock()
+	d.deps = deps
+	for _, dir := range deps.EmbedDirs() {
+		if !d.embedded[dir] {
+			d.embedded[dir] = true
+			added = append(added, dir)
+		}
+	}
+	d.mu.Unlock()
+
+	// embedded files are watched even in hidden or excluded directories
+	for _, dir := range added {
+		if err := d.watcher.Add(dir); err != nil {
+			logger.Print("error: ", err)
+		}
+	}
+	return deps, nil
+}
+
+// rewatch watches the directories of embedded files again after dir, or the
+// tree it belonged to, was recreated, reporting whether it held any
+func (d *dependencies) rewatch(dir string) bool {
+	d.mu.Lock()
+	var dirs []string
+	for embedded := range d.embedded {
+		if rel, err := filepath.Rel(dir, embedded); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			dirs = append(dirs, embedded)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, embedded := range dirs {
+		if err := d.watcher.Add(embedded); err != nil && !os.IsNotExist(err) {
+			logger.Print("error: ", err)
+		}
+	}
+	return len(dirs) > 0
+}
+
+// modules returns the local modules of the last listed build
+func (d *dependencies) modules() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.deps == nil {
+		return nil
+	}
+	return d.deps.Modules()
+}
+
+// affects reports whether a change to the Go file affects the build; every
+// file does until the dependencies have been listed
+func (d *dependencies) affects(file string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.deps == nil || d.deps.Contains(file)
+}
+
+// embeds reports whether the file is embedded into the build
+func (d *dependencies) embeds(file string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.deps != nil && d.deps.Embeds(file)
+}
+
+// moduleFile reports whether file defines modules or a workspace
+func moduleFile(file string) bool {
+	name := filepath.Base(file)
+	return name == "go.mod" || name == "go.work"
+}
@@ -0,0 +1,88 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/n3integration/reload/runtime"
+)
+
+// Init writes a project configuration file based on the project in the
+// working directory and the global flags
+func Init(c *cli.Context) {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	path := c.GlobalString("config")
+	if path == "" {
+		path = filepath.Join(wd, runtime.ConfigFile)
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		logger.Fatal(err)
+	}
+	if _, err := os.Stat(path); err == nil && !c.Bool("force") {
+		logger.Fatalf("%s already exists, use --force to overwrite it\n", path)
+	}
+
+	project, err := runtime.InspectProject(wd)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	config := new(runtime.Config)
+	if err := applyFlags(c, config, true); err != nil {
+		logger.Fatal(err)
+	}
+	project.Configure(config)
+	relocate(config, wd, filepath.Dir(path))
+
+	var out bytes.Buffer
+	if err := project.WriteConfig(&out, config); err != nil {
+		logger.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, out.Bytes(), 0644); err != nil {
+		logger.Fatal(err)
+	}
+
+	if project.Module != "" {
+		logger.Printf("Found module %s\n", project.Module)
+	}
+	if project.Main == "" {
+		logger.Println("No main package found, set \"build\" to its directory")
+	}
+	if project.Env {
+		logger.Println("The variables in .env are added to the app's environment")
+	}
+	logger.Printf("Wrote %s\n", path)
+}
+
+// relocate rewrites the relative paths of config, given from the working
+// directory, relative to the directory of the configuration file
+func relocate(config *runtime.Config, wd string, dir string) {
+	rel := func(value *string) {
+		if *value == "" || filepath.IsAbs(*value) {
+			return
+		}
+		if path, err := filepath.Rel(dir, filepath.Join(wd, *value)); err == nil {
+			*value = filepath.ToSlash(path)
+		}
+	}
+
+	rel(&config.Path)
+	rel(&config.Build)
+	rel(&config.CertFile)
+	rel(&config.KeyFile)
+	for i := range config.ExcludeDirs {
+		rel(&config.ExcludeDirs[i])
+	}
+}
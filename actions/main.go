@@ -1,17 +1,18 @@
 package actions
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strconv"
-	"strings"
 	"syscall"
 	"time"
 
-	"golang.org/x/time/rate"
 	"gopkg.in/urfave/cli.v1"
 
 	"github.com/0xAX/notificator"
@@ -26,82 +27,250 @@ var notifier = notificator.New(notificator.Options{
 })
 
 func Main(c *cli.Context) {
-	laddr := c.GlobalString("laddr")
-	port := c.GlobalInt("port")
-	all := c.GlobalBool("all")
-	appPort := strconv.Itoa(c.GlobalInt("appPort"))
-	immediate = c.GlobalBool("immediate")
-	keyFile := c.GlobalString("keyFile")
-	certFile := c.GlobalString("certFile")
-	logPrefix := c.GlobalString("logPrefix")
-	notifications = c.GlobalBool("notifications")
-
-	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+	config, configPath, err := loadConfig(c)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := config.Validate(); err != nil {
+		logger.Fatal(err)
+	}
+
+	immediate = config.Immediate
+	notifications = config.Notifications
+	verbose = config.Verbose
+
+	logger.SetPrefix(fmt.Sprintf("[%s] ", config.LogPrefix))
+	if configPath != "" {
+		logger.Printf("Using configuration from %s\n", configPath)
+	}
 
 	envy.Bootstrap()
-	os.Setenv("PORT", appPort)
+	os.Setenv("PORT", strconv.Itoa(config.AppPort))
 
 	wd, err := os.Getwd()
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	buildArgs, err := shellwords.Parse(c.GlobalString("buildArgs"))
+	buildArgs, err := shellwords.Parse(config.BuildArgs)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	buildPath := c.GlobalString("build")
-	if buildPath == "" {
-		buildPath = c.GlobalString("path")
+	state := runtime.NewBuildState()
+	builder := runtime.NewBuilder(config.Build, config.Bin, wd, buildArgs, state)
+	runner := runtime.NewRunner(filepath.Join(wd, builder.Binary()), state, c.Args()...)
+	runner.SetWriter(os.Stdout)
+	if err := configureStop(runner, config); err != nil {
+		logger.Fatal(err)
+	}
+	if config.SocketActivation {
+		socket, err := runtime.ListenSocket(config.ProxyTo)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		runner.SetListener(socket)
+		logger.Printf("Passing the app a socket listening on %s\n", config.ProxyTo)
+	}
+
+	// in blue/green mode requests are only held until the app first starts,
+	// then the running app serves them while the next build is in progress
+	serving := state
+	if config.BlueGreen {
+		serving = runtime.NewBuildState()
 	}
+	proxy := runtime.NewProxy(builder, runner, serving)
 
-	builder := runtime.NewBuilder(buildPath, c.GlobalString("bin"), wd, buildArgs)
-	runner := runtime.NewRunner(filepath.Join(wd, builder.Binary()), c.Args()...)
-	runner.SetWriter(os.Stdout)
-	proxy := runtime.NewProxy(builder, runner)
+	start := func(ctx context.Context) error {
+		if immediate {
+			runner.Run()
+		}
+		return nil
+	}
+	var bg *blueGreen
+	if config.BlueGreen {
+		if bg, err = newBlueGreen(runner, proxy, config); err != nil {
+			logger.Fatal(err)
+		}
+		start = bg.replace
+		logger.Printf("Alternating the app between ports %d and %d\n", config.AppPort, config.AltPort)
+	}
+
+	rules, err := runtime.NewRules(config.Path, config.Rules)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
-	config := &runtime.Config{
-		Laddr:    laddr,
-		Port:     port,
-		ProxyTo:  "http://localhost:" + appPort,
-		KeyFile:  keyFile,
-		CertFile: certFile,
+	if config.TLS {
+		if err := localCertificate(config); err != nil {
+			logger.Fatal(err)
+		}
 	}
 
+	// requests arriving before the initial build completes are held
+	serving.Begin()
+
 	err = proxy.Run(config)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	if laddr != "" {
-		logger.Printf("Listening at %s:%d\n", laddr, port)
+	if config.Laddr != "" {
+		logger.Printf("Listening at %s:%d\n", config.Laddr, config.Port)
 	} else {
-		logger.Printf("Listening on port %d\n", port)
+		logger.Printf("Listening on port %d\n", config.Port)
 	}
 
 	shutdown(runner)
 
 	// build right now
-	build(builder, runner, logger)
+	build(context.Background(), builder, start, proxy, logger)
+	if config.BlueGreen {
+		serving.End(nil)
+	}
 
-	// scan for changes
-	scanChanges(c.GlobalString("path"), c.GlobalStringSlice("excludeDir"), all, func(path string) {
-		runner.Kill()
-		build(builder, runner, logger)
+	// scan for changes, superseding any build still in flight
+	watcher, err := runtime.NewWatcher(config.Watcher, time.Duration(config.PollInterval))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	deps := newDependencies(builder.Dir(), buildArgs, watcher)
+	filter := runtime.NewFilter(config.Path, config)
+	rebuilds := newRebuilder(func(ctx context.Context) {
+		if bg == nil {
+			// hold proxied requests from the moment the old binary goes away
+			state.Begin()
+			proxy.Disconnect()
+			runner.Kill()
+		}
+		build(ctx, builder, start, proxy, logger)
+		// the changes may have added or removed imports, including of local
+		// modules; a failed build still lists its packages, so that fixing
+		// a newly imported one rebuilds
+		if ctx.Err() == nil {
+			watchModules(watcher, filter, deps)
+		}
+	})
+
+	scanChanges(watcher, config.Path, filter, rules, deps, time.Duration(config.Debounce), func(paths []string, action runtime.Action) {
+		logger.Printf("%d file(s) changed\n", len(paths))
+		switch action.Kind {
+		case runtime.ActionRebuild:
+			rebuilds.trigger()
+		case runtime.ActionRestart:
+			rebuilds.run(func() {
+				logger.Println("Restarting...")
+				if bg != nil {
+					if err := bg.replace(context.Background()); err != nil {
+						logger.Print("failed to restart: ", err)
+						proxy.Reload(err)
+						return
+					}
+					proxy.Reload(nil)
+					return
+				}
+				// hold proxied requests until the app is stopped, so that
+				// browsers reconnect to the restarted app
+				state.Begin()
+				proxy.Disconnect()
+				if err := runner.Kill(); err != nil {
+					logger.Print("failed to terminate: ", err)
+				}
+				state.End(state.Err())
+				if immediate {
+					runner.Run()
+				}
+				proxy.Reload(nil)
+			})
+		case runtime.ActionSignal:
+			rebuilds.run(func() {
+				logger.Printf("Sending %v signal\n", action.Signal)
+				if err := runner.Signal(action.Signal); err != nil {
+					logger.Print("failed to signal: ", err)
+				}
+				proxy.Reload(nil)
+			})
+		case runtime.ActionRefresh:
+			proxy.Reload(nil)
+		}
 	})
 }
 
-func build(builder runtime.Builder, runner runtime.Runner, logger *log.Logger) {
+// configureStop sets how the app is stopped: the pre-stop hook requested on
+// it, the signal it is sent and how long it is given to exit
+func configureStop(runner runtime.Runner, config *runtime.Config) error {
+	var sig os.Signal = os.Interrupt
+	if config.StopSignal != "" {
+		parsed, err := runtime.ParseStopSignal(config.StopSignal)
+		if err != nil {
+			return err
+		}
+		sig = parsed
+	}
+
+	var preStop *url.URL
+	if config.PreStop != "" {
+		to, err := url.Parse(config.ProxyTo)
+		if err != nil {
+			return err
+		}
+		hook, err := url.Parse(config.PreStop)
+		if err != nil {
+			return err
+		}
+		preStop = to.ResolveReference(hook)
+	}
+
+	runner.SetStop(preStop, sig, time.Duration(config.StopTimeout))
+	return nil
+}
+
+// localCertificate serves the proxy with a certificate for localhost and the
+// listening address, signed by a certificate authority cached for the user
+func localCertificate(config *runtime.Config) error {
+	dir, err := runtime.CertificateDir()
+	if err != nil {
+		return err
+	}
+
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if config.Laddr != "" && config.Laddr != "0.0.0.0" && config.Laddr != "::" {
+		hosts = append(hosts, config.Laddr)
+	}
+
+	local, err := runtime.EnsureLocalCertificate(dir, hosts)
+	if err != nil {
+		return err
+	}
+	config.CertFile, config.KeyFile = local.CertFile, local.KeyFile
+	logger.Printf("Serving HTTPS, trust %s once to avoid certificate warnings\n", local.CAFile)
+	return nil
+}
+
+// build rebuilds the binary and calls start once it succeeds, returning the
+// build error, or the context's error when the build is cancelled
+func build(ctx context.Context, builder runtime.Builder, start func(ctx context.Context) error, proxy runtime.Proxy, logger *log.Logger) error {
 	logger.Println("Building...")
 	if notifications {
 		notifier.Push("Build Started", "Building "+builder.Binary()+"...", "", notificator.UR_NORMAL)
 	}
 
-	if err := builder.Build(); err == nil {
+	err := builder.Build(ctx)
+	if ctx.Err() != nil {
+		logger.Println("Build cancelled")
+		return ctx.Err()
+	}
+
+	if err == nil {
 		logger.Printf("%sBuild complete%s\n", colorGreen, colorReset)
-		if immediate {
-			runner.Run()
+		if err := start(ctx); ctx.Err() != nil {
+			logger.Println("Start cancelled")
+			return ctx.Err()
+		} else if err != nil {
+			logger.Print("failed to start the app: ", err)
+			proxy.Reload(err)
+		} else {
+			proxy.Reload(nil)
 		}
 		if notifications {
 			if err := notifier.Push("Build Succeeded", "Build Complete", "", notificator.UR_CRITICAL); err != nil {
@@ -111,78 +280,208 @@ func build(builder runtime.Builder, runner runtime.Runner, logger *log.Logger) {
 	} else {
 		logger.Printf("%sBuild failed%s\n", colorRed, colorReset)
 		fmt.Println(builder.Errors())
-		buildErrors := strings.Split(builder.Errors(), "\n")
+		proxy.Reload(err)
 		if notifications {
-			if err := notifier.Push("Build Failed", buildErrors[1], "", notificator.UR_CRITICAL); err != nil {
+			message := "Build failed"
+			if diagnostics := builder.Diagnostics(); len(diagnostics) > 0 {
+				message = diagnostics[0].String()
+			}
+			if err := notifier.Push("Build Failed", message, "", notificator.UR_CRITICAL); err != nil {
 				logger.Println("failed to publish notification")
 			}
 		}
 	}
 
 	time.Sleep(100 * time.Millisecond)
+	return err
 }
 
-type scanCallback func(path string)
+type scanCallback func(paths []string, action runtime.Action)
 
-func scanChanges(watchPath string, excludeDirs []string, allFiles bool, cb scanCallback) {
-	watcher, _ := fsnotify.NewWatcher()
-	throttle := rate.NewLimiter(rate.Every(time.Second*3), 1)
+// scanChanges invokes cb with every changed path, and the action handling all
+// of them, once the watched tree has been quiet for the debounce period.
+// Files matching a rule take its action and other changes rebuild. Go files
+// outside of the dependencies of the build are ignored, files embedded into
+// it are always relevant, and the local modules of the build are watched
+// initially and whenever go.mod or go.work change.
+func scanChanges(watcher runtime.Watcher, watchPath string, filter *runtime.Filter, rules *runtime.Rules, deps *dependencies, debounce time.Duration, cb scanCallback) {
 	defer watcher.Close()
 
-	if err := walk(watcher, watchPath, excludeDirs); err != nil {
+	if err := walk(watcher, watchPath, filter); err != nil {
 		logger.Print("error:", err)
 	}
+	watchModules(watcher, filter, deps)
+
+	pending := make(map[string]struct{})
+	var action runtime.Action
+	quiet := time.NewTimer(debounce)
+	quiet.Stop()
 
 	for {
 		select {
-		case event := <-watcher.Events:
-			switch event.Op {
-			case fsnotify.Create:
-				if info, err := os.Stat(event.Name); err == nil {
-					if info.IsDir() {
-						walk(watcher, event.Name, excludeDirs)
-					}
-				}
-			case fsnotify.Write:
-				if allFiles || filepath.Ext(event.Name) == ".go" {
-					if throttle.Allow() {
-						cb(event.Name)
+		case err := <-watcher.Errors():
+			logger.Print("watch error: ", err)
+		case event := <-watcher.Events():
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					walk(watcher, event.Name, filter)
+					if deps.rewatch(event.Name) {
+						// the embedded files were replaced, such as by a
+						// frontend build
+						pending[event.Name] = struct{}{}
+						action = action.Merge(runtime.Rebuild)
+						quiet.Reset(debounce)
 					}
+					continue
 				}
-			case fsnotify.Remove:
+			}
+			if event.Op&fsnotify.Remove != 0 {
 				watcher.Remove(event.Name)
 			}
+			if filter.Reload(event.Name) {
+				continue
+			}
+
+			// editors and vcs tools replace files as often as they write them
+			changed := fsnotify.Write | fsnotify.Create | fsnotify.Remove | fsnotify.Rename
+			if event.Op&changed == 0 {
+				continue
+			}
+			matched, ok := rules.Match(event.Name)
+			switch {
+			case moduleFile(event.Name):
+				watchModules(watcher, filter, deps)
+				matched = runtime.Rebuild
+			case deps.embeds(event.Name):
+				// embedded assets are compiled in, whatever the watch patterns
+				// and rules, so anything short of a rebuild would keep serving
+				// the old copy
+				if _, seen := pending[event.Name]; ok && !seen && matched.Kind != runtime.ActionRebuild {
+					logger.Printf("Rebuilding for %s: embedded in the binary, so the %s rule does not apply\n", event.Name, matched)
+				}
+				matched = runtime.Rebuild
+			case ok && !filter.Excluded(event.Name):
+				// rules apply whatever the watch patterns
+			case !filter.File(event.Name):
+				if verbose {
+					logger.Printf("Ignoring %s: not matched by the watch patterns\n", event.Name)
+				}
+				continue
+			case filepath.Ext(event.Name) == ".go" && !deps.affects(event.Name):
+				if verbose {
+					logger.Printf("Ignoring %s: not part of the build\n", event.Name)
+				}
+				continue
+			default:
+				matched = runtime.Rebuild
+			}
+			pending[event.Name] = struct{}{}
+			action = action.Merge(matched)
+			quiet.Reset(debounce)
+		case <-quiet.C:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			pending = make(map[string]struct{})
+			cb(paths, action)
+			action = runtime.Action{}
+		}
+	}
+}
+
+// watchModules watches the local modules the build depends on, such as those
+// replaced with a directory in go.mod or used from a go.work workspace
+func watchModules(watcher runtime.Watcher, filter *runtime.Filter, dependencies *dependencies) {
+	// both the watcher and the builds refresh the modules
+	dependencies.watching.Lock()
+	defer dependencies.watching.Unlock()
+
+	previous := dependencies.modules()
+	deps, err := dependencies.load()
+	if err != nil {
+		logger.Print("error: ", err)
+		return
+	}
+
+	modules := deps.Modules()
+	used := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		used[module] = true
+	}
+
+	// modules no longer replaced or part of the workspace stop triggering
+	// rebuilds
+	for _, module := range previous {
+		if !used[module] && filter.RemoveRoot(module) {
+			logger.Printf("No longer watching module %s\n", module)
+			unwatch(watcher, module, filter)
+		}
+	}
+
+	for _, module := range modules {
+		if filter.AddRoot(module) {
+			logger.Printf("Watching module %s\n", module)
+			if err := walk(watcher, module, filter); err != nil {
+				logger.Print("error: ", err)
+			}
+		}
+	}
+
+	// the workspace file commonly lives above the watched modules
+	if deps.Workspace != "" {
+		if err := watcher.Add(filepath.Dir(deps.Workspace)); err != nil {
+			logger.Print("error: ", err)
 		}
 	}
 }
 
-func walk(watcher *fsnotify.Watcher, watchPath string, excludeDirs []string) error {
+func walk(watcher runtime.Watcher, watchPath string, filter *runtime.Filter) error {
 	return filepath.Walk(watchPath, func(path string, info os.FileInfo, err error) error {
-		for _, x := range excludeDirs {
-			if x == path {
+		// entries can vanish mid-walk, such as an editor's temporary files;
+		// the rest of the tree is still watched
+		if err != nil {
+			logger.Print("error: ", err)
+			if info != nil && info.IsDir() {
 				return filepath.SkipDir
 			}
+			return nil
 		}
 
-		if path == "." {
-			return watcher.Add(path)
+		if !info.IsDir() {
+			return nil
 		}
 
-		if (path == "vendor" || filepath.Base(path)[0] == '.') && info.IsDir() {
+		if !filter.Dir(path) {
 			return filepath.SkipDir
 		}
 
-		if info.IsDir() {
-			return watcher.Add(path)
+		return watcher.Add(path)
+	})
+}
+
+// unwatch stops watching the directories of a tree, apart from those still
+// part of a watched one
+func unwatch(watcher runtime.Watcher, watchPath string, filter *runtime.Filter) {
+	filepath.Walk(watchPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if filter.Dir(path) {
+			return filepath.SkipDir
 		}
 
+		watcher.Remove(path)
 		return nil
 	})
 }
 
 func shutdown(runner runtime.Runner) {
 	c := make(chan os.Signal, 2)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	// the app runs in a process group of its own, so the hangup of a closed
+	// terminal and Ctrl-\ only reach reload
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
 	go func() {
 		s := <-c
 		log.Println("received signal: ", s)
@@ -0,0 +1,68 @@
+package actions
+
+import (
+	"context"
+	"sync"
+)
+
+// rebuilder runs one build at a time, cancelling the build in flight when a
+// newer change arrives so that the latest state on disk is what gets built.
+// Restarts and signals take their turn with the builds.
+type rebuilder struct {
+	mu     sync.Mutex
+	build  func(ctx context.Context)
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newRebuilder(build func(ctx context.Context)) *rebuilder {
+	return &rebuilder{build: build}
+}
+
+// trigger cancels any build in flight and starts a new build in the
+// background once the previous job has stopped, without waiting for it, so
+// that changes keep being watched meanwhile
+func (r *rebuilder) trigger() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	previous := r.done
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	r.cancel = cancel
+	r.done = done
+
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		// a newer change may have superseded the build while it waited
+		if ctx.Err() == nil {
+			r.build(ctx)
+		}
+	}()
+}
+
+// run runs fn in the background once the build in flight, if any, is done,
+// so that restarting or signalling the app does not interleave with a build
+func (r *rebuilder) run(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.done
+	done := make(chan struct{})
+	r.done = done
+
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		fn()
+	}()
+}
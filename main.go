@@ -2,10 +2,12 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"gopkg.in/urfave/cli.v1"
 
 	"github.com/n3integration/reload/actions"
+	"github.com/n3integration/reload/runtime"
 )
 
 func main() {
@@ -14,6 +16,11 @@ func main() {
 	app.Usage = "A live reload utility for Go web applications."
 	app.Action = actions.Main
 	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "config,c",
+			EnvVar: "RELOAD_CONFIG",
+			Usage:  "Configuration file (defaults to the nearest " + runtime.ConfigFile + " in the working directory or its parents)",
+		},
 		cli.StringFlag{
 			Name:   "laddr,l",
 			Value:  "",
@@ -32,6 +39,26 @@ func main() {
 			EnvVar: "BIN_APP_PORT",
 			Usage:  "Port for the Go web server",
 		},
+		cli.BoolFlag{
+			Name:   "blueGreen",
+			EnvVar: "RELOAD_BLUE_GREEN",
+			Usage:  "Starts each new build next to the running app and switches over once it is ready, avoiding downtime",
+		},
+		cli.IntFlag{
+			Name:   "altPort",
+			EnvVar: "RELOAD_ALT_PORT",
+			Usage:  "Port the Go web server alternates with appPort in blue/green mode (defaults to appPort + 1)",
+		},
+		cli.BoolFlag{
+			Name:   "socketActivation",
+			EnvVar: "RELOAD_SOCKET_ACTIVATION",
+			Usage:  "Passes the Go web server a socket listening on appPort, following the systemd LISTEN_FDS convention, instead of having it bind PORT",
+		},
+		cli.BoolFlag{
+			Name:   "h2c",
+			EnvVar: "RELOAD_H2C",
+			Usage:  "Speaks HTTP/2 without TLS to the Go web server for every request, not only gRPC calls",
+		},
 		cli.StringFlag{
 			Name:   "bin,b",
 			Value:  "reload-bin",
@@ -56,16 +83,101 @@ func main() {
 			EnvVar: "RELOAD_EXCLUDE_DIR",
 			Usage:  "Relative directories to exclude",
 		},
+		cli.StringSliceFlag{
+			Name:   "include",
+			Value:  &cli.StringSlice{},
+			EnvVar: "RELOAD_INCLUDE",
+			Usage:  "Glob patterns of files that trigger a rebuild, e.g. '**/*.tmpl' or '!**/*_test.go' (defaults to .go files)",
+		},
+		cli.StringSliceFlag{
+			Name:   "exclude",
+			Value:  &cli.StringSlice{},
+			EnvVar: "RELOAD_EXCLUDE",
+			Usage:  "Glob patterns of files and directories to ignore, e.g. '**/node_modules' or '!vendor'",
+		},
+		cli.BoolFlag{
+			Name:   "gitignore",
+			EnvVar: "RELOAD_GITIGNORE",
+			Usage:  "Ignores files matched by .gitignore files in the watched tree",
+		},
 		cli.BoolFlag{
 			Name:   "immediate,i",
 			EnvVar: "RELOAD_IMMEDIATE",
 			Usage:  "Run the server immediately after it's built",
 		},
+		cli.DurationFlag{
+			Name:   "buildWait",
+			Value:  30 * time.Second,
+			EnvVar: "RELOAD_BUILD_WAIT",
+			Usage:  "Maximum time to hold requests while a build is in progress (0 waits indefinitely)",
+		},
+		cli.StringFlag{
+			Name:   "watcher",
+			Value:  "auto",
+			EnvVar: "RELOAD_WATCHER",
+			Usage:  "How to detect changes: notify, poll, or auto to poll only when filesystem notifications are unavailable",
+		},
+		cli.DurationFlag{
+			Name:   "pollInterval",
+			Value:  500 * time.Millisecond,
+			EnvVar: "RELOAD_POLL_INTERVAL",
+			Usage:  "Interval between scans when polling for changes",
+		},
+		cli.DurationFlag{
+			Name:   "debounce",
+			Value:  500 * time.Millisecond,
+			EnvVar: "RELOAD_DEBOUNCE",
+			Usage:  "Quiet period after the last change before rebuilding",
+		},
 		cli.BoolFlag{
 			Name:   "all",
 			EnvVar: "RELOAD_ALL",
 			Usage:  "Reloads whenever any file changes, as opposed to reloading only on .go file change",
 		},
+		cli.StringSliceFlag{
+			Name:   "rule",
+			Value:  &cli.StringSlice{},
+			EnvVar: "RELOAD_RULE",
+			Usage:  "Action taken when files matching a pattern change, as pattern=action where action is rebuild, restart, refresh or signal:NAME",
+		},
+		cli.DurationFlag{
+			Name:   "readyTimeout",
+			Value:  30 * time.Second,
+			EnvVar: "RELOAD_READY_TIMEOUT",
+			Usage:  "Maximum time to wait for the Go web server to accept requests after starting (0 disables the check)",
+		},
+		cli.StringFlag{
+			Name:   "healthPath",
+			EnvVar: "RELOAD_HEALTH_PATH",
+			Usage:  "HTTP path polled to determine the Go web server is ready (defaults to a TCP connection check)",
+		},
+		cli.StringFlag{
+			Name:   "stopSignal",
+			Value:  "INT",
+			EnvVar: "RELOAD_STOP_SIGNAL",
+			Usage:  "Signal asking the Go web server to exit: INT, TERM, QUIT or HUP",
+		},
+		cli.DurationFlag{
+			Name:   "stopTimeout",
+			Value:  3 * time.Second,
+			EnvVar: "RELOAD_STOP_TIMEOUT",
+			Usage:  "Time the Go web server is given to exit before it is killed",
+		},
+		cli.StringFlag{
+			Name:   "preStop",
+			EnvVar: "RELOAD_PRE_STOP",
+			Usage:  "HTTP path requested on the Go web server before it is asked to exit",
+		},
+		cli.BoolFlag{
+			Name:   "liveReload",
+			EnvVar: "RELOAD_LIVE_RELOAD",
+			Usage:  "Injects a script into HTML responses that refreshes the browser after each build",
+		},
+		cli.StringFlag{
+			Name:   "editor",
+			EnvVar: "RELOAD_EDITOR",
+			Usage:  "Editor to link error locations to: vscode, idea, sublime, atom or a url template using {file}, {line} and {column}",
+		},
 		cli.StringFlag{
 			Name:   "buildArgs",
 			EnvVar: "RELOAD_BUILD_ARGS",
@@ -81,6 +193,11 @@ func main() {
 			EnvVar: "RELOAD_KEY_FILE",
 			Usage:  "TLS Certificate Key",
 		},
+		cli.BoolFlag{
+			Name:   "tls",
+			EnvVar: "RELOAD_TLS",
+			Usage:  "Serves HTTPS with a generated certificate for localhost, signed by a local certificate authority",
+		},
 		cli.StringFlag{
 			Name:   "logPrefix",
 			EnvVar: "RELOAD_LOG_PREFIX",
@@ -92,6 +209,11 @@ func main() {
 			EnvVar: "RELOAD_NOTIFICATIONS",
 			Usage:  "Enables desktop notifications",
 		},
+		cli.BoolFlag{
+			Name:   "verbose",
+			EnvVar: "RELOAD_VERBOSE",
+			Usage:  "Logs why changed files did not trigger a rebuild",
+		},
 	}
 	app.Commands = []cli.Command{
 		{
@@ -106,6 +228,28 @@ func main() {
 			Usage:     "Display environment variables set by the .env file",
 			Action:    actions.Env,
 		},
+		{
+			Name:  "config",
+			Usage: "Inspect the configuration",
+			Subcommands: []cli.Command{
+				{
+					Name:   "check",
+					Usage:  "Validate the configuration and print it as resolved from flags, environment and " + runtime.ConfigFile,
+					Action: actions.ConfigCheck,
+				},
+			},
+		},
+		{
+			Name:   "init",
+			Usage:  "Write a " + runtime.ConfigFile + " configuration file for the project in the current working directory",
+			Action: actions.Init,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "force,f",
+					Usage: "Overwrites an existing configuration file",
+				},
+			},
+		},
 	}
 	app.Run(os.Args)
 }
@@ -1,33 +1,43 @@
 package runtime
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 )
 
 // Builder provides a binary builder
 type Builder interface {
-	// Build creates the temporal executable
-	Build() error
+	// Build creates the temporal executable, stopping early and returning the
+	// context's error if ctx is cancelled
+	Build(ctx context.Context) error
 	// Binary returns the reference to the runtime binary
 	Binary() string
+	// Dir returns the directory the binary is built from
+	Dir() string
 	// Errors returns any errors from the executable
 	Errors() string
+	// Diagnostics returns the errors of the last build, one per problem
+	Diagnostics() []Diagnostic
 }
 
 type builder struct {
+	mu        sync.Mutex
 	dir       string
 	binary    string
 	errors    string
+	diags     []Diagnostic
 	wd        string
 	buildArgs []string
+	state     *BuildState
 }
 
 // New constructs a new Builder
-func NewBuilder(dir string, bin string, wd string, buildArgs []string) Builder {
+func NewBuilder(dir string, bin string, wd string, buildArgs []string, state *BuildState) Builder {
 	if len(bin) == 0 {
 		bin = "bin"
 	}
@@ -39,34 +49,70 @@ func NewBuilder(dir string, bin string, wd string, buildArgs []string) Builder {
 		}
 	}
 
-	return &builder{dir: dir, binary: bin, wd: wd, buildArgs: buildArgs}
+	if state == nil {
+		state = NewBuildState()
+	}
+
+	return &builder{dir: dir, binary: bin, wd: wd, buildArgs: buildArgs, state: state}
 }
 
 func (b *builder) Binary() string {
 	return b.binary
 }
 
+func (b *builder) Dir() string {
+	return b.dir
+}
+
 func (b *builder) Errors() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	return b.errors
 }
 
-func (b *builder) Build() error {
+func (b *builder) Diagnostics() []Diagnostic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.diags
+}
+
+func (b *builder) Build(ctx context.Context) (err error) {
+	b.state.Begin()
+	defer func() {
+		// a cancelled build has been superseded, and the build replacing it
+		// is the one to report a result
+		if ctx.Err() == nil {
+			b.state.End(err)
+		}
+	}()
+
 	args := append([]string{"go", "build", "-o", filepath.Join(b.wd, b.binary)}, b.buildArgs...)
 
 	var command *exec.Cmd
-	command = exec.Command(args[0], args[1:]...)
+	command = exec.CommandContext(ctx, args[0], args[1:]...)
 	command.Dir = b.dir
 
 	output, err := command.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	if command.ProcessState.Success() {
+	switch {
+	case command.ProcessState == nil:
+		// go itself could not be started
+		b.errors = err.Error()
+	case command.ProcessState.Success():
 		b.errors = ""
-	} else {
+	default:
 		b.errors = string(output)
 	}
+	b.diags = ParseDiagnostics(b.errors)
 
 	if len(b.errors) > 0 {
-		return fmt.Errorf(b.errors)
+		return errors.New(b.errors)
 	}
 
 	return err
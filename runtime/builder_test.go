@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -21,8 +22,8 @@ func Test_Builder_Build_Success(t *testing.T) {
 		t.Fatalf("Could not get working directory: %v", err)
 	}
 
-	builder := NewBuilder(dir, bin, wd, []string{})
-	err = builder.Build()
+	builder := NewBuilder(dir, bin, wd, []string{}, NewBuildState())
+	err = builder.Build(context.Background())
 	test.Expect(t, err, nil)
 
 	file, err := os.Open(filepath.Join(wd, bin))
@@ -32,3 +33,57 @@ func Test_Builder_Build_Success(t *testing.T) {
 
 	test.Refute(t, file, nil)
 }
+
+func Test_Builder_Build_Cancelled(t *testing.T) {
+	dir := filepath.Join("testdata", "build_success")
+	state := NewBuildState()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Could not get working directory: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	builder := NewBuilder(dir, "build_cancelled", wd, []string{}, state)
+	err = builder.Build(ctx)
+	test.Expect(t, err, context.Canceled)
+	test.Expect(t, builder.Errors(), "")
+	test.Expect(t, len(builder.Diagnostics()), 0)
+
+	// the build that supersedes a cancelled one reports the result
+	test.Expect(t, state.Building(), true)
+	state.End(nil)
+}
+
+// mockBuilder is a Builder reporting MockErrors as the result of the last
+// build; it lives here rather than in the test package, which cannot refer
+// to Diagnostic without an import cycle
+type mockBuilder struct {
+	MockErrors string
+}
+
+func newMockBuilder() *mockBuilder {
+	return &mockBuilder{}
+}
+
+func (m *mockBuilder) Binary() string {
+	return "bin"
+}
+
+func (m *mockBuilder) Dir() string {
+	return "."
+}
+
+func (m *mockBuilder) Build(ctx context.Context) error {
+	return nil
+}
+
+func (m *mockBuilder) Errors() string {
+	return m.MockErrors
+}
+
+func (m *mockBuilder) Diagnostics() []Diagnostic {
+	return ParseDiagnostics(m.MockErrors)
+}
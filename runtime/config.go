@@ -3,30 +3,142 @@ package runtime
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"time"
 )
 
+// ConfigFile is the name of the project configuration file
+const ConfigFile = ".reload.json"
+
 type Config struct {
-	Laddr    string `json:"laddr"`
-	Port     int    `json:"port"`
-	ProxyTo  string `json:"proxy_to"`
-	KeyFile  string `json:"key_file"`
-	CertFile string `json:"cert_file"`
+	Laddr            string       `json:"laddr"`
+	Port             int          `json:"port"`
+	AppPort          int          `json:"app_port"`
+	BlueGreen        bool         `json:"blue_green"`
+	AltPort          int          `json:"alt_port"`
+	SocketActivation bool         `json:"socket_activation"`
+	ProxyTo          string       `json:"proxy_to"`
+	H2C              bool         `json:"h2c"`
+	Bin              string       `json:"bin"`
+	Path             string       `json:"path"`
+	Build            string       `json:"build"`
+	BuildArgs        string       `json:"build_args"`
+	KeyFile          string       `json:"key_file"`
+	CertFile         string       `json:"cert_file"`
+	TLS              bool         `json:"tls"`
+	Immediate        bool         `json:"immediate"`
+	BuildWait        Duration     `json:"build_wait"`
+	LiveReload       bool         `json:"live_reload"`
+	ReadyTimeout     Duration     `json:"ready_timeout"`
+	HealthPath       string       `json:"health_path"`
+	StopSignal       string       `json:"stop_signal"`
+	StopTimeout      Duration     `json:"stop_timeout"`
+	PreStop          string       `json:"pre_stop"`
+	Editor           string       `json:"editor"`
+	Include          []string     `json:"include"`
+	Exclude          []string     `json:"exclude"`
+	ExcludeDirs      []string     `json:"exclude_dirs"`
+	All              bool         `json:"all"`
+	GitIgnore        bool         `json:"gitignore"`
+	Watcher          string       `json:"watcher"`
+	PollInterval     Duration     `json:"poll_interval"`
+	Debounce         Duration     `json:"debounce"`
+	Rules            []ActionRule `json:"rules"`
+	LogPrefix        string       `json:"log_prefix"`
+	Notifications    bool         `json:"notifications"`
+	Verbose          bool         `json:"verbose"`
 }
 
-func LoadConfig(path string) (*Config, error) {
-	configFile, err := os.Open(path)
+// Duration is a time.Duration that is read from configuration files as
+// either a duration string (e.g. "30s") or a number of nanoseconds
+type Duration time.Duration
 
-	if err != nil {
-		return nil, fmt.Errorf("unable to read configuration file %s", path)
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
 	}
 
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func LoadConfig(path string) (*Config, error) {
 	config := new(Config)
-	decoder := json.NewDecoder(configFile)
-	err = decoder.Decode(&config)
+	if err := config.Merge(path); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Merge reads the configuration file at path over c, leaving the settings the
+// file omits untouched. Keys starting with // are comments, and relative
+// paths in the file are resolved against the directory containing it.
+func (c *Config) Merge(path string) error {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse configuration file %s", path)
+		return fmt.Errorf("unable to read configuration file %s", path)
 	}
 
-	return config, nil
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("unable to parse configuration file %s", path)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("unable to parse configuration file %s", path)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(key string, value *string) {
+		if _, ok := keys[key]; ok && *value != "" && !filepath.IsAbs(*value) {
+			*value = filepath.Join(dir, *value)
+		}
+	}
+	resolve("path", &c.Path)
+	resolve("build", &c.Build)
+	resolve("key_file", &c.KeyFile)
+	resolve("cert_file", &c.CertFile)
+	if _, ok := keys["exclude_dirs"]; ok {
+		for i := range c.ExcludeDirs {
+			resolve("exclude_dirs", &c.ExcludeDirs[i])
+		}
+	}
+
+	return nil
+}
+
+// FindConfig looks for the configuration file in dir and each of its parents,
+// returning its path or false if there is none
+func FindConfig(dir string) (string, bool) {
+	dir = abs(dir)
+	for {
+		path := filepath.Join(dir, ConfigFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
 }
@@ -1,7 +1,11 @@
 package runtime
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/n3integration/reload/test"
 )
@@ -12,6 +16,9 @@ func Test_LoadConfig(t *testing.T) {
 	test.Expect(t, err, nil)
 	test.Expect(t, config.Port, 5678)
 	test.Expect(t, config.ProxyTo, "http://localhost:3000")
+	test.Expect(t, time.Duration(config.BuildWait), 45*time.Second)
+	test.Expect(t, len(config.Rules), 1)
+	test.Expect(t, config.Rules[0], ActionRule{Pattern: "templates/**", Action: "signal:HUP"})
 }
 
 func Test_LoadConfig_WithNonExistentFile(t *testing.T) {
@@ -27,3 +34,59 @@ func Test_LoadConfig_WithMalformedFile(t *testing.T) {
 	test.Refute(t, err, nil)
 	test.Expect(t, err.Error(), "unable to parse configuration file testdata/bad_config.json")
 }
+
+func Test_Config_Merge(t *testing.T) {
+	config := &Config{Port: 3000, Bin: "reload-bin", Immediate: true}
+	err := config.Merge("testdata/config.json")
+
+	test.Expect(t, err, nil)
+	test.Expect(t, config.Port, 5678)
+	test.Expect(t, config.Bin, "reload-bin")
+	test.Expect(t, config.Immediate, true)
+}
+
+func Test_Config_Merge_ResolvesPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ConfigFile)
+	content := `{"path": "app", "cert_file": "/etc/app.crt", "exclude_dirs": ["app/tmp"]}`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Could not write %s: %v", path, err)
+	}
+
+	config := &Config{Build: "cmd/app"}
+	test.Expect(t, config.Merge(path), nil)
+	test.Expect(t, config.Path, filepath.Join(dir, "app"))
+	test.Expect(t, config.Build, "cmd/app")
+	test.Expect(t, config.CertFile, "/etc/app.crt")
+	test.Expect(t, config.ExcludeDirs[0], filepath.Join(dir, "app", "tmp"))
+}
+
+func Test_FindConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	nested := filepath.Join(dir, "cmd", "app")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Could not create %s: %v", nested, err)
+	}
+
+	_, ok := FindConfig(nested)
+	test.Expect(t, ok, false)
+
+	path := filepath.Join(dir, ConfigFile)
+	if err := ioutil.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Could not write %s: %v", path, err)
+	}
+
+	found, ok := FindConfig(nested)
+	test.Expect(t, ok, true)
+	test.Expect(t, found, path)
+}
@@ -0,0 +1,115 @@
+package runtime
+
+import (
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// crashReport describes an app process that exited unexpectedly
+type crashReport struct {
+	Status string
+	Code   int
+	Panic  *panicTrace
+	Output string
+}
+
+func newCrashReport(state *os.ProcessState, output string, editor string) *crashReport {
+	trace := parsePanic(output)
+	if trace != nil {
+		for _, g := range trace.Goroutines {
+			for i, frame := range g.Frames {
+				g.Frames[i].Link = editorLink(editor, frame.File, frame.Line, 0)
+			}
+		}
+	}
+
+	return &crashReport{
+		Status: state.String(),
+		Code:   state.ExitCode(),
+		Panic:  trace,
+		Output: output,
+	}
+}
+
+// panicTrace is the parsed form of a Go panic or fatal error
+type panicTrace struct {
+	Message    string
+	Goroutines []goroutineTrace
+}
+
+type goroutineTrace struct {
+	Header string
+	Frames []stackFrame
+}
+
+type stackFrame struct {
+	Func string
+	File string
+	Line int
+	Link template.URL
+}
+
+// parsePanic extracts the panic message and goroutine traces from the output
+// of a Go program, returning nil if the output contains no panic
+func parsePanic(output string) *panicTrace {
+	lines := strings.Split(output, "\n")
+
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	trace := &panicTrace{Message: lines[start]}
+	current := -1
+	for _, line := range lines[start+1:] {
+		switch {
+		case strings.HasPrefix(line, "goroutine ") && strings.HasSuffix(line, ":"):
+			trace.Goroutines = append(trace.Goroutines, goroutineTrace{Header: strings.TrimSuffix(line, ":")})
+			current = len(trace.Goroutines) - 1
+		case current < 0:
+			// nested panics and multi-line messages precede the first goroutine
+			if len(trace.Goroutines) == 0 && strings.TrimSpace(line) != "" {
+				trace.Message += "\n" + strings.TrimSpace(line)
+			}
+		case line == "":
+			current = -1
+		case strings.HasPrefix(line, "\t"):
+			frames := trace.Goroutines[current].Frames
+			if len(frames) > 0 {
+				frames[len(frames)-1].File, frames[len(frames)-1].Line = parseLocation(line)
+			}
+		default:
+			trace.Goroutines[current].Frames = append(trace.Goroutines[current].Frames, stackFrame{Func: line})
+		}
+	}
+
+	return trace
+}
+
+// parseLocation splits a trace location such as "\t/src/main.go:12 +0x1d"
+// into its file and line
+func parseLocation(location string) (string, int) {
+	location = strings.TrimSpace(location)
+	if i := strings.LastIndex(location, " +0x"); i >= 0 {
+		location = location[:i]
+	}
+
+	i := strings.LastIndex(location, ":")
+	if i < 0 {
+		return location, 0
+	}
+
+	line, err := strconv.Atoi(location[i+1:])
+	if err != nil {
+		return location, 0
+	}
+	return location[:i], line
+}
@@ -0,0 +1,46 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+var panicOutput = `starting up
+panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4a1b2c]
+
+goroutine 1 [running]:
+main.handler(...)
+	/src/app/handler.go:21
+main.main()
+	/src/app/main.go:12 +0x25
+
+goroutine 6 [select]:
+net/http.(*persistConn).writeLoop(0xc000100000)
+	/usr/local/go/src/net/http/transport.go:2421 +0xe5
+created by net/http.(*Transport).dialConn in goroutine 1
+	/usr/local/go/src/net/http/transport.go:1777 +0x16f1
+`
+
+func Test_ParsePanic(t *testing.T) {
+	trace := parsePanic(panicOutput)
+
+	test.Refute(t, trace == nil, true)
+	test.Expect(t, trace.Message, "panic: runtime error: invalid memory address or nil pointer dereference\n"+
+		"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4a1b2c]")
+	test.Expect(t, len(trace.Goroutines), 2)
+
+	main := trace.Goroutines[0]
+	test.Expect(t, main.Header, "goroutine 1 [running]")
+	test.Expect(t, len(main.Frames), 2)
+	test.Expect(t, main.Frames[0], stackFrame{Func: "main.handler(...)", File: "/src/app/handler.go", Line: 21})
+	test.Expect(t, main.Frames[1], stackFrame{Func: "main.main()", File: "/src/app/main.go", Line: 12})
+
+	test.Expect(t, len(trace.Goroutines[1].Frames), 2)
+	test.Expect(t, trace.Goroutines[1].Frames[1].Line, 1777)
+}
+
+func Test_ParsePanic_WithoutPanic(t *testing.T) {
+	test.Expect(t, parsePanic("listening on :8080\n") == nil, true)
+}
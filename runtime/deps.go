@@ -0,0 +1,260 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Package is the subset of the output of go list used to decide what to watch
+type Package struct {
+	ImportPath     string
+	Dir            string
+	Standard       bool
+	Module         *Module
+	GoFiles        []string
+	CgoFiles       []string
+	CFiles         []string
+	CXXFiles       []string
+	HFiles         []string
+	SFiles         []string
+	SysoFiles      []string
+	IgnoredGoFiles []string
+	EmbedFiles     []string
+	EmbedPatterns  []string
+}
+
+// sources returns the files of the package that are compiled into the build,
+// relative to its directory
+func (p *Package) sources() [][]string {
+	return [][]string{p.GoFiles, p.CgoFiles, p.CFiles, p.CXXFiles, p.HFiles, p.SFiles, p.SysoFiles, p.EmbedFiles}
+}
+
+// Module is a module providing one or more packages of the build
+type Module struct {
+	Path    string
+	Version string
+	Dir     string
+	Main    bool
+	Replace *Module
+}
+
+// Local reports whether the module's source lives on the local filesystem,
+// either as a main or workspace module or through a directory replacement
+func (m *Module) Local() bool {
+	return m.Main || (m.Replace != nil && m.Replace.Version == "")
+}
+
+// Deps describes the packages that feed a build
+type Deps struct {
+	Packages []Package
+	// Workspace is the go.work file in effect, if any
+	Workspace string
+}
+
+// LoadDeps lists the dependencies of the packages built from dir with the
+// given go build arguments
+func LoadDeps(ctx context.Context, dir string, buildArgs []string) (*Deps, error) {
+	args := append([]string{"list", "-e", "-deps", "-json"}, buildArgs...)
+	command := exec.CommandContext(ctx, "go", args...)
+	command.Dir = dir
+
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list dependencies: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	deps := new(Deps)
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var pkg Package
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to parse dependencies: %v", err)
+		}
+		deps.Packages = append(deps.Packages, pkg)
+	}
+	deps.Workspace = workspace(ctx, dir)
+
+	return deps, nil
+}
+
+// workspace returns the go.work file used from dir; releases of go without
+// workspaces report none
+func workspace(ctx context.Context, dir string) string {
+	command := exec.CommandContext(ctx, "go", "env", "GOWORK")
+	command.Dir = dir
+	output, err := command.Output()
+	if err != nil {
+		return ""
+	}
+
+	file := strings.TrimSpace(string(output))
+	if file == "off" {
+		return ""
+	}
+	return file
+}
+
+// Contains reports whether a change to file affects the build, either as a
+// source or embedded file of one of its packages or as a Go file added to the
+// directory of one
+func (d *Deps) Contains(file string) bool {
+	file = abs(file)
+
+	for i := range d.Packages {
+		pkg := &d.Packages[i]
+		if pkg.Standard || pkg.Dir == "" {
+			continue
+		}
+
+		rel, ok := within(pkg.Dir, file)
+		if !ok {
+			continue
+		}
+
+		for _, files := range pkg.sources() {
+			for _, name := range files {
+				if name == rel {
+					return true
+				}
+			}
+		}
+
+		if !strings.Contains(rel, "/") && newGoFile(pkg, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Embeds reports whether file is embedded into the build with a //go:embed
+// directive, including files added since the dependencies were listed
+func (d *Deps) Embeds(file string) bool {
+	file = abs(file)
+
+	for i := range d.Packages {
+		pkg := &d.Packages[i]
+		if pkg.Standard || pkg.Dir == "" || len(pkg.EmbedPatterns) == 0 {
+			continue
+		}
+
+		rel, ok := within(pkg.Dir, file)
+		if !ok {
+			continue
+		}
+
+		for _, name := range pkg.EmbedFiles {
+			if name == rel {
+				return true
+			}
+		}
+		for _, pattern := range pkg.EmbedPatterns {
+			if matchEmbed(pattern, rel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchEmbed matches a path relative to the package directory against a
+// //go:embed pattern. A pattern matching a directory embeds the files below
+// it, except those whose names begin with "." or "_" unless the pattern is
+// prefixed with "all:".
+func matchEmbed(pattern string, rel string) bool {
+	all := strings.HasPrefix(pattern, "all:")
+	pattern = strings.TrimPrefix(pattern, "all:")
+
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		if ok, _ := path.Match(pattern, strings.Join(parts[:i], "/")); !ok {
+			continue
+		}
+		if all {
+			return true
+		}
+		for _, part := range parts[i:] {
+			if strings.HasPrefix(part, ".") || strings.HasPrefix(part, "_") {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// within returns the slash separated path of file relative to dir, or false
+// if file is outside of dir
+func within(dir string, file string) (string, bool) {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// newGoFile reports whether name is a Go file that go list has yet to see,
+// which joins the package unless excluded by its build constraints
+func newGoFile(pkg *Package, name string) bool {
+	if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	for _, ignored := range pkg.IgnoredGoFiles {
+		if name == ignored {
+			return false
+		}
+	}
+	return true
+}
+
+// Modules returns the directories of the local modules in the build
+func (d *Deps) Modules() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+
+	for _, pkg := range d.Packages {
+		if pkg.Standard || pkg.Module == nil || !pkg.Module.Local() || pkg.Module.Dir == "" {
+			continue
+		}
+		if !seen[pkg.Module.Dir] {
+			seen[pkg.Module.Dir] = true
+			dirs = append(dirs, pkg.Module.Dir)
+		}
+	}
+
+	sort.Strings(dirs)
+	return dirs
+}
+
+// EmbedDirs returns the directories of the files embedded into the build
+func (d *Deps) EmbedDirs() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+
+	for _, pkg := range d.Packages {
+		if pkg.Standard || pkg.Dir == "" {
+			continue
+		}
+		for _, name := range pkg.EmbedFiles {
+			dir := filepath.Dir(filepath.Join(pkg.Dir, name))
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	sort.Strings(dirs)
+	return dirs
+}
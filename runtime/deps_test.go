@@ -0,0 +1,190 @@
+package runtime
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_LoadDeps_Modules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// resolve symlinked temp directories, as go list reports real paths
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	app := filepath.Join(dir, "app")
+	shared := filepath.Join(dir, "shared")
+	writeFiles(t, map[string]string{
+		filepath.Join(app, "go.mod"): "module example.com/app\n\ngo 1.16\n\n" +
+			"require example.com/shared v0.0.0\n\nreplace example.com/shared => ../shared\n",
+		filepath.Join(app, "main.go"):      "package main\n\nimport \"example.com/shared\"\n\nfunc main() { shared.Hello() }\n",
+		filepath.Join(shared, "go.mod"):    "module example.com/shared\n\ngo 1.16\n",
+		filepath.Join(shared, "shared.go"): "package shared\n\nfunc Hello() {}\n",
+	})
+
+	deps, err := LoadDeps(context.Background(), app, []string{})
+	if err != nil {
+		t.Fatalf("Could not load dependencies: %v", err)
+	}
+
+	modules := deps.Modules()
+	test.Expect(t, len(modules), 2)
+	test.Expect(t, modules[0], app)
+	test.Expect(t, modules[1], shared)
+}
+
+func Test_LoadDeps_Workspace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	app := filepath.Join(dir, "app")
+	shared := filepath.Join(dir, "shared")
+	writeFiles(t, map[string]string{
+		filepath.Join(dir, "go.work"):      "go 1.18\n\nuse (\n\t./app\n\t./shared\n)\n",
+		filepath.Join(app, "go.mod"):       "module example.com/app\n\ngo 1.18\n",
+		filepath.Join(app, "main.go"):      "package main\n\nimport \"example.com/shared\"\n\nfunc main() { shared.Hello() }\n",
+		filepath.Join(shared, "go.mod"):    "module example.com/shared\n\ngo 1.18\n",
+		filepath.Join(shared, "shared.go"): "package shared\n\nfunc Hello() {}\n",
+	})
+
+	// -mod=mod is not allowed in workspace mode
+	defer os.Setenv("GOFLAGS", os.Getenv("GOFLAGS"))
+	os.Setenv("GOFLAGS", "")
+
+	deps, err := LoadDeps(context.Background(), app, []string{})
+	if err != nil {
+		t.Fatalf("Could not load dependencies: %v", err)
+	}
+	test.Expect(t, deps.Workspace, filepath.Join(dir, "go.work"))
+
+	modules := deps.Modules()
+	test.Expect(t, len(modules), 2)
+	test.Expect(t, modules[0], app)
+	test.Expect(t, modules[1], shared)
+}
+
+func writeFiles(t *testing.T, files map[string]string) {
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("Could not create %s: %v", filepath.Dir(name), err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write %s: %v", name, err)
+		}
+	}
+}
+
+func Test_Deps_Contains(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	writeFiles(t, map[string]string{
+		filepath.Join(dir, "go.mod"):                   "module example.com/app\n\ngo 1.16\n",
+		filepath.Join(dir, "main.go"):                  "package main\n\nimport (\n\t\"embed\"\n\n\t\"example.com/app/lib\"\n)\n\n//go:embed static\nvar static embed.FS\n\nfunc main() { lib.Hello() }\n",
+		filepath.Join(dir, "main_test.go"):             "package main\n",
+		filepath.Join(dir, "tools.go"):                 "//go:build tools\n// +build tools\n\npackage main\n",
+		filepath.Join(dir, "lib", "lib.go"):            "package lib\n\nfunc Hello() {}\n",
+		filepath.Join(dir, "cmd", "tool", "main.go"):   "package main\n\nfunc main() {}\n",
+		filepath.Join(dir, "static", "index.html"):     "<html></html>\n",
+		filepath.Join(dir, "static", "css", "app.css"): "body {}\n",
+	})
+
+	deps, err := LoadDeps(context.Background(), dir, []string{})
+	if err != nil {
+		t.Fatalf("Could not load dependencies: %v", err)
+	}
+
+	test.Expect(t, deps.Contains(filepath.Join(dir, "main.go")), true)
+	test.Expect(t, deps.Contains(filepath.Join(dir, "lib", "lib.go")), true)
+	test.Expect(t, deps.Contains(filepath.Join(dir, "lib", "added.go")), true)
+	test.Expect(t, deps.Contains(filepath.Join(dir, "static", "css", "app.css")), true)
+	test.Expect(t, deps.Contains(filepath.Join(dir, "main_test.go")), false)
+	test.Expect(t, deps.Contains(filepath.Join(dir, "tools.go")), false)
+	test.Expect(t, deps.Contains(filepath.Join(dir, "cmd", "tool", "main.go")), false)
+	test.Expect(t, deps.Contains(filepath.Join(dir, "README.md")), false)
+}
+
+func Test_LoadDeps_Failed_Build(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	writeFiles(t, map[string]string{
+		filepath.Join(dir, "go.mod"):            "module example.com/app\n\ngo 1.16\n",
+		filepath.Join(dir, "main.go"):           "package main\n\nimport \"example.com/app/util\"\n\nfunc main() { util.Hello() }\n",
+		filepath.Join(dir, "util", "util.go"):   "package util\n\nfunc Hello() {\n",
+		filepath.Join(dir, "tools", "tools.go"): "package tools\n",
+	})
+
+	// a newly imported package that does not compile is part of the build,
+	// so that fixing it rebuilds
+	deps, err := LoadDeps(context.Background(), dir, []string{})
+	if err != nil {
+		t.Fatalf("Could not load dependencies: %v", err)
+	}
+	test.Expect(t, deps.Contains(filepath.Join(dir, "util", "util.go")), true)
+	test.Expect(t, deps.Contains(filepath.Join(dir, "tools", "tools.go")), false)
+}
+
+func Test_Deps_Embeds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	writeFiles(t, map[string]string{
+		filepath.Join(dir, "go.mod"):                       "module example.com/app\n\ngo 1.16\n",
+		filepath.Join(dir, "main.go"):                      "package main\n\nimport \"embed\"\n\n//go:embed static templates/*.html\nvar assets embed.FS\n\nfunc main() {}\n",
+		filepath.Join(dir, "static", "app.css"):            "body {}\n",
+		filepath.Join(dir, "templates", "index.html"):      "<html></html>\n",
+		filepath.Join(dir, "templates", "index.html.orig"): "<html></html>\n",
+	})
+
+	deps, err := LoadDeps(context.Background(), dir, []string{})
+	if err != nil {
+		t.Fatalf("Could not load dependencies: %v", err)
+	}
+
+	test.Expect(t, deps.Embeds(filepath.Join(dir, "static", "app.css")), true)
+	test.Expect(t, deps.Embeds(filepath.Join(dir, "static", "js", "added.js")), true)
+	test.Expect(t, deps.Embeds(filepath.Join(dir, "static", ".DS_Store")), false)
+	test.Expect(t, deps.Embeds(filepath.Join(dir, "templates", "index.html")), true)
+	test.Expect(t, deps.Embeds(filepath.Join(dir, "templates", "added.html")), true)
+	test.Expect(t, deps.Embeds(filepath.Join(dir, "templates", "index.html.orig")), false)
+	test.Expect(t, deps.Embeds(filepath.Join(dir, "main.go")), false)
+
+	dirs := deps.EmbedDirs()
+	test.Expect(t, len(dirs), 2)
+	test.Expect(t, dirs[0], filepath.Join(dir, "static"))
+	test.Expect(t, dirs[1], filepath.Join(dir, "templates"))
+}
+
+func Test_matchEmbed(t *testing.T) {
+	test.Expect(t, matchEmbed("static", "static/css/app.css"), true)
+	test.Expect(t, matchEmbed("static", "static/_draft.css"), false)
+	test.Expect(t, matchEmbed("all:static", "static/_draft.css"), true)
+	test.Expect(t, matchEmbed("*.tmpl", "index.tmpl"), true)
+	test.Expect(t, matchEmbed("*.tmpl", "views/index.tmpl"), false)
+	test.Expect(t, matchEmbed("views/*.tmpl", "views/index.tmpl"), true)
+}
@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a single problem reported by go build
+type Diagnostic struct {
+	// Package is the import path of the package being compiled, if known
+	Package string `json:"package,omitempty"`
+	// File is the source file as reported by the compiler
+	File string `json:"file,omitempty"`
+	// Line is the 1-based line number, or 0 when not reported
+	Line int `json:"line,omitempty"`
+	// Column is the 1-based column number, or 0 when not reported
+	Column int `json:"column,omitempty"`
+	// Message describes the problem
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	switch {
+	case d.File == "":
+		return d.Message
+	case d.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+	default:
+		return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+	}
+}
+
+var diagnosticPattern = regexp.MustCompile(`^(.+?\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// ParseDiagnostics converts the combined output of go build into diagnostics,
+// in the order they were reported
+func ParseDiagnostics(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	pkg := ""
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			continue
+		case strings.HasPrefix(line, "# "):
+			pkg = strings.TrimPrefix(line, "# ")
+		case strings.HasPrefix(line, "\t") && len(diagnostics) > 0:
+			// continuation of the previous message, e.g. have/want details
+			last := &diagnostics[len(diagnostics)-1]
+			last.Message += "\n" + strings.TrimSpace(line)
+		default:
+			diagnostics = append(diagnostics, parseDiagnostic(pkg, line))
+		}
+	}
+
+	return diagnostics
+}
+
+func parseDiagnostic(pkg string, line string) Diagnostic {
+	match := diagnosticPattern.FindStringSubmatch(line)
+	if match == nil {
+		return Diagnostic{Package: pkg, Message: strings.TrimSpace(line)}
+	}
+
+	d := Diagnostic{Package: pkg, File: match[1], Message: match[4]}
+	d.Line, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		d.Column, _ = strconv.Atoi(match[3])
+	}
+	return d
+}
@@ -0,0 +1,37 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+var buildOutput = `# github.com/example/app
+./main.go:10:2: undefined: handler
+./main.go:14:9: cannot use x (variable of type int) as string value in return statement
+	have (int)
+	want (string)
+handlers/user.go:3: imported and not used: "fmt"
+note: module requires Go 1.99
+`
+
+func Test_ParseDiagnostics(t *testing.T) {
+	diagnostics := ParseDiagnostics(buildOutput)
+
+	test.Expect(t, len(diagnostics), 4)
+	test.Expect(t, diagnostics[0], Diagnostic{
+		Package: "github.com/example/app",
+		File:    "./main.go",
+		Line:    10,
+		Column:  2,
+		Message: "undefined: handler",
+	})
+	test.Expect(t, diagnostics[1].Message, "cannot use x (variable of type int) as string value in return statement\nhave (int)\nwant (string)")
+	test.Expect(t, diagnostics[2].String(), `handlers/user.go:3: imported and not used: "fmt"`)
+	test.Expect(t, diagnostics[3].File, "")
+	test.Expect(t, diagnostics[3].String(), "note: module requires Go 1.99")
+}
+
+func Test_ParseDiagnostics_Empty(t *testing.T) {
+	test.Expect(t, len(ParseDiagnostics("")), 0)
+}
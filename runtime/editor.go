@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	"html/template"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// editors maps well-known editor names to their url templates
+var editors = map[string]string{
+	"vscode":  "vscode://file{file}:{line}:{column}",
+	"idea":    "idea://open?file={file}&line={line}",
+	"sublime": "subl://open?url=file://{file}&line={line}",
+	"atom":    "atom://core/open/file?filename={file}&line={line}&column={column}",
+}
+
+// editorLink expands the url template of the named editor, or the custom
+// template itself, for the given location. An empty editor yields no link.
+func editorLink(editor string, file string, line int, column int) template.URL {
+	if editor == "" || file == "" {
+		return ""
+	}
+
+	pattern, ok := editors[editor]
+	if !ok {
+		pattern = editor
+	}
+
+	if column < 1 {
+		column = 1
+	}
+
+	// {file} always expands to a rooted, slash separated path
+	path := filepath.ToSlash(file)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	path = (&url.URL{Path: path}).EscapedPath()
+	link := strings.NewReplacer(
+		"{file}", path,
+		"{line}", strconv.Itoa(line),
+		"{column}", strconv.Itoa(column),
+	).Replace(pattern)
+
+	// the link is trusted configuration, so custom schemes are allowed
+	return template.URL(link)
+}
@@ -0,0 +1,15 @@
+package runtime
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_EditorLink(t *testing.T) {
+	test.Expect(t, editorLink("", "/src/main.go", 10, 2), template.URL(""))
+	test.Expect(t, editorLink("vscode", "/src/main.go", 10, 2), template.URL("vscode://file/src/main.go:10:2"))
+	test.Expect(t, editorLink("idea", "/my src/main.go", 10, 0), template.URL("idea://open?file=/my%20src/main.go&line=10"))
+	test.Expect(t, editorLink("custom://{file}#L{line}", "/src/main.go", 7, 0), template.URL("custom:///src/main.go#L7"))
+}
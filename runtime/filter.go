@@ -0,0 +1,348 @@
+package runtime
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ignoreFiles are read from every watched directory; .gitignore files are
+// only honoured when enabled in the configuration
+var ignoreFiles = []string{".gitignore", ".reloadignore"}
+
+// defaultExcludes keeps vendored code and hidden directories out of the watch
+// unless re-included with a negated exclude pattern such as "!vendor"
+var defaultExcludes = []string{"/vendor/", ".*/"}
+
+// Filter decides which paths under the watched roots are relevant
+type Filter struct {
+	mu          sync.Mutex
+	roots       []string
+	include     []rule
+	exclude     []rule
+	excludeDirs []string
+	all         bool
+	gitignore   bool
+	ignores     map[string][]rule
+}
+
+// NewFilter constructs a new Filter for the tree at root. Patterns use the
+// .gitignore syntax, with "**" matching any number of directories, and
+// include patterns prefixed with "!" exclude matching paths.
+func NewFilter(root string, config *Config) *Filter {
+	f := &Filter{
+		roots:     []string{abs(root)},
+		all:       config.All,
+		gitignore: config.GitIgnore,
+		ignores:   make(map[string][]rule),
+	}
+
+	for _, pattern := range defaultExcludes {
+		f.exclude = append(f.exclude, parseRule(pattern))
+	}
+	for _, dir := range config.ExcludeDirs {
+		// directories are given relative to the working directory
+		f.excludeDirs = append(f.excludeDirs, abs(dir))
+	}
+	for _, pattern := range config.Exclude {
+		f.exclude = append(f.exclude, parseRule(pattern))
+	}
+	for _, pattern := range config.Include {
+		if strings.HasPrefix(pattern, "!") {
+			f.exclude = append(f.exclude, parseRule(pattern[1:]))
+		} else {
+			f.include = append(f.include, parseRule(pattern))
+		}
+	}
+
+	return f
+}
+
+// AddRoot watches another tree, such as a module outside of the first root,
+// and reports whether dir was not already part of a watched tree
+func (f *Filter) AddRoot(dir string) bool {
+	dir = abs(dir)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, _, ok := f.rel(dir); ok {
+		return false
+	}
+	f.roots = append(f.roots, dir)
+	return true
+}
+
+// RemoveRoot stops watching a tree added with AddRoot, reporting whether dir
+// was one; the first root is always watched
+func (f *Filter) RemoveRoot(dir string) bool {
+	dir = abs(dir)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := 1; i < len(f.roots); i++ {
+		if f.roots[i] == dir {
+			f.roots = append(f.roots[:i], f.roots[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Roots returns the absolute paths of the watched trees
+func (f *Filter) Roots() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.roots...)
+}
+
+// Dir reports whether the directory should be watched, loading its ignore
+// files when it is
+func (f *Filter) Dir(dir string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	root, rel, ok := f.rel(dir)
+	if !ok {
+		return false
+	}
+
+	if rel != "." && f.excluded(root, rel, true) {
+		return false
+	}
+	f.load(root, rel)
+	return true
+}
+
+// Excluded reports whether the file is excluded by a pattern or ignore file,
+// regardless of the include patterns
+func (f *Filter) Excluded(file string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	root, rel, ok := f.rel(file)
+	return !ok || rel == "." || f.excluded(root, rel, false)
+}
+
+// File reports whether a change to the file is relevant
+func (f *Filter) File(file string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	root, rel, ok := f.rel(file)
+	if !ok || rel == "." {
+		return false
+	}
+
+	if f.excluded(root, rel, false) {
+		return false
+	}
+
+	if len(f.include) == 0 {
+		return f.all || path.Ext(rel) == ".go"
+	}
+	for _, r := range f.include {
+		if r.match(rel, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload rereads the ignore files of the directory containing file, and
+// reports whether file was an ignore file
+func (f *Filter) Reload(file string) bool {
+	name := filepath.Base(file)
+	for _, ignore := range ignoreFiles {
+		if name == ignore {
+			f.mu.Lock()
+			if root, rel, ok := f.rel(filepath.Dir(file)); ok {
+				delete(f.ignores, filepath.Join(root, filepath.FromSlash(rel)))
+				f.load(root, rel)
+			}
+			f.mu.Unlock()
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether rel, or any directory containing it, is excluded
+func (f *Filter) excluded(root string, rel string, dir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		isDir := dir || i < len(parts)
+		if f.ignored(root, strings.Join(parts[:i], "/"), isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored applies the exclude patterns and then the ignore files of every
+// directory above rel; as with .gitignore the last matching pattern wins
+func (f *Filter) ignored(root string, rel string, dir bool) bool {
+	if dir {
+		full := filepath.Join(root, filepath.FromSlash(rel))
+		for _, x := range f.excludeDirs {
+			if full == x {
+				return true
+			}
+		}
+	}
+
+	ignored := false
+	for _, r := range f.exclude {
+		if r.match(rel, dir) {
+			ignored = !r.negate
+		}
+	}
+
+	base := root
+	parts := strings.Split(rel, "/")
+	for i := 0; i < len(parts); i++ {
+		sub := strings.Join(parts[i:], "/")
+		for _, r := range f.ignores[base] {
+			if r.match(sub, dir) {
+				ignored = !r.negate
+			}
+		}
+		base = filepath.Join(base, parts[i])
+	}
+
+	return ignored
+}
+
+// load reads the ignore files of a directory relative to a root
+func (f *Filter) load(root string, rel string) {
+	dir := filepath.Join(root, filepath.FromSlash(rel))
+	if _, ok := f.ignores[dir]; ok {
+		return
+	}
+
+	var rules []rule
+	for _, name := range ignoreFiles {
+		if name == ".gitignore" && !f.gitignore {
+			continue
+		}
+		rules = append(rules, readIgnoreFile(filepath.Join(dir, name))...)
+	}
+	f.ignores[dir] = rules
+}
+
+// rel returns the innermost root containing file and the slash separated path
+// of file relative to it, or false if file is outside of every root
+func (f *Filter) rel(file string) (string, string, bool) {
+	file = abs(file)
+
+	found, relative := "", ""
+	for _, root := range f.roots {
+		rel, err := filepath.Rel(root, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(root) > len(found) {
+			found, relative = root, filepath.ToSlash(rel)
+		}
+	}
+	return found, relative, found != ""
+}
+
+func abs(file string) string {
+	if a, err := filepath.Abs(file); err == nil {
+		return a
+	}
+	return filepath.Clean(file)
+}
+
+func readIgnoreFile(file string) []rule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []rule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, parseRule(line))
+	}
+	return rules
+}
+
+// rule is a single .gitignore style pattern
+type rule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func parseRule(pattern string) rule {
+	r := rule{}
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	// patterns containing a separator are relative to the root, others
+	// match at any depth
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if strings.Contains(pattern, "/") {
+		r.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	r.pattern = pattern
+
+	return r
+}
+
+func (r rule) match(rel string, dir bool) bool {
+	if r.dirOnly && !dir {
+		return false
+	}
+	if r.anchored {
+		return matchGlob(r.pattern, rel)
+	}
+	return matchGlob("**/"+r.pattern, rel)
+}
+
+// matchGlob matches a slash separated path against a pattern in which "**"
+// matches zero or more path segments
+func matchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
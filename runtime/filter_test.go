@@ -0,0 +1,113 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_MatchGlob(t *testing.T) {
+	test.Expect(t, matchGlob("**/*.tmpl", "index.tmpl"), true)
+	test.Expect(t, matchGlob("**/*.tmpl", "views/users/index.tmpl"), true)
+	test.Expect(t, matchGlob("views/**", "views"), true)
+	test.Expect(t, matchGlob("views/**/*.html", "views/a/b/c.html"), true)
+	test.Expect(t, matchGlob("*.go", "cmd/main.go"), false)
+	test.Expect(t, matchGlob("tmp", "tmp/x"), false)
+}
+
+func Test_Filter_Defaults(t *testing.T) {
+	filter := NewFilter("project", &Config{})
+
+	test.Expect(t, filter.Dir("project"), true)
+	test.Expect(t, filter.Dir("project/handlers"), true)
+	test.Expect(t, filter.Dir("project/vendor"), false)
+	test.Expect(t, filter.Dir("project/internal/vendor"), true)
+	test.Expect(t, filter.Dir("project/.git"), false)
+	test.Expect(t, filter.Dir("elsewhere"), false)
+
+	test.Expect(t, filter.File("project/main.go"), true)
+	test.Expect(t, filter.File("project/handlers/user.go"), true)
+	test.Expect(t, filter.File("project/vendor/lib/lib.go"), false)
+	test.Expect(t, filter.File("project/index.html"), false)
+	test.Expect(t, filter.File("./project/.hidden/main.go"), false)
+}
+
+func Test_Filter_Patterns(t *testing.T) {
+	filter := NewFilter(".", &Config{
+		Include:     []string{"**/*.go", "**/*.tmpl", "!**/*_test.go"},
+		Exclude:     []string{"**/node_modules", "!vendor"},
+		ExcludeDirs: []string{"./tmp"},
+	})
+
+	test.Expect(t, filter.Dir("tmp"), false)
+	test.Expect(t, filter.Dir("./tmp"), false)
+	test.Expect(t, filter.Dir("web/tmp"), true)
+	test.Expect(t, filter.Dir("web/node_modules"), false)
+	test.Expect(t, filter.Dir("vendor"), true)
+
+	test.Expect(t, filter.File("main.go"), true)
+	test.Expect(t, filter.File("main_test.go"), false)
+	test.Expect(t, filter.File("views/index.tmpl"), true)
+	test.Expect(t, filter.File("web/node_modules/x/index.tmpl"), false)
+	test.Expect(t, filter.File("tmp/main.go"), false)
+	test.Expect(t, filter.File("README.md"), false)
+
+	all := NewFilter(".", &Config{All: true})
+	test.Expect(t, all.File("README.md"), true)
+}
+
+func Test_Filter_IgnoreFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "web"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte("# build output\n/dist/\n*.gen.go\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "web", ".reloadignore"), []byte("assets/\n!keep.gen.go\n"), 0644)
+
+	filter := NewFilter(dir, &Config{})
+	test.Expect(t, filter.Dir(dir), true)
+	test.Expect(t, filter.Dir(filepath.Join(dir, "dist")), true)
+	test.Expect(t, filter.Dir(filepath.Join(dir, "web")), true)
+	test.Expect(t, filter.Dir(filepath.Join(dir, "web", "assets")), false)
+
+	filter = NewFilter(dir, &Config{GitIgnore: true})
+	test.Expect(t, filter.Dir(dir), true)
+	test.Expect(t, filter.Dir(filepath.Join(dir, "dist")), false)
+	test.Expect(t, filter.Dir(filepath.Join(dir, "web", "dist")), true)
+	test.Expect(t, filter.File(filepath.Join(dir, "models.gen.go")), false)
+	test.Expect(t, filter.Dir(filepath.Join(dir, "web")), true)
+	test.Expect(t, filter.File(filepath.Join(dir, "web", "keep.gen.go")), true)
+	test.Expect(t, filter.File(filepath.Join(dir, "web", "other.gen.go")), false)
+
+	ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte("/dist/\n"), 0644)
+	test.Expect(t, filter.Reload(filepath.Join(dir, ".gitignore")), true)
+	test.Expect(t, filter.File(filepath.Join(dir, "models.gen.go")), true)
+	test.Expect(t, filter.Reload(filepath.Join(dir, "main.go")), false)
+}
+
+func Test_Filter_AddRoot(t *testing.T) {
+	filter := NewFilter("app", &Config{ExcludeDirs: []string{"app/tmp"}})
+
+	test.Expect(t, filter.AddRoot("app/internal"), false)
+	test.Expect(t, filter.AddRoot("shared"), true)
+	test.Expect(t, filter.AddRoot("shared"), false)
+	test.Expect(t, len(filter.Roots()), 2)
+
+	test.Expect(t, filter.Dir("shared"), true)
+	test.Expect(t, filter.Dir("shared/vendor"), false)
+	test.Expect(t, filter.Dir("app/tmp"), false)
+	test.Expect(t, filter.File("shared/util.go"), true)
+	test.Expect(t, filter.File("other/util.go"), false)
+	test.Expect(t, filter.Excluded("shared/.cache/util.go"), true)
+
+	test.Expect(t, filter.RemoveRoot("app"), false)
+	test.Expect(t, filter.RemoveRoot("shared"), true)
+	test.Expect(t, filter.RemoveRoot("shared"), false)
+	test.Expect(t, filter.File("shared/util.go"), false)
+}
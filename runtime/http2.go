@@ -0,0 +1,54 @@
+package runtime
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+// backendTransport sends gRPC calls to the app over HTTP/2, which gRPC
+// requires, and other requests over the default transport. A cleartext app
+// is spoken to with HTTP/2 prior knowledge (h2c), and with h2c set every
+// request to it is.
+type backendTransport struct {
+	h1  http.RoundTripper
+	h2c http.RoundTripper
+	all bool
+}
+
+func newBackendTransport(all bool) *backendTransport {
+	// the app is a development server, commonly with a self-signed certificate
+	h1 := http.DefaultTransport.(*http.Transport).Clone()
+	h1.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	return &backendTransport{
+		h1: h1,
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+		all: all,
+	}
+}
+
+func (t *backendTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" && (t.all || grpc(req)) {
+		return t.h2c.RoundTrip(req)
+	}
+	// the default transport negotiates HTTP/2 with apps serving TLS
+	return t.h1.RoundTrip(req)
+}
+
+// grpc reports whether req is a gRPC call; gRPC-Web calls work over HTTP/1.1
+// and are excluded
+func grpc(req *http.Request) bool {
+	contentType := req.Header.Get("Content-Type")
+	return strings.HasPrefix(contentType, "application/grpc") && !strings.HasPrefix(contentType, "application/grpc-web")
+}
@@ -0,0 +1,187 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	liveReloadPrefix = "/__reload/"
+	liveReloadEvents = liveReloadPrefix + "events"
+	liveReloadClient = liveReloadPrefix + "client.js"
+	liveReloadScript = `<script src="` + liveReloadClient + `"></script>`
+)
+
+type event struct {
+	name string
+	data string
+}
+
+// liveReload streams build events to connected browsers
+type liveReload struct {
+	mu      sync.Mutex
+	clients map[chan event]struct{}
+}
+
+func newLiveReload() *liveReload {
+	return &liveReload{clients: make(map[chan event]struct{})}
+}
+
+// publish sends an event to every connected browser
+func (l *liveReload) publish(name, data string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for client := range l.clients {
+		select {
+		case client <- event{name: name, data: data}:
+		default:
+			// slow clients miss events rather than stall the build
+		}
+	}
+}
+
+func (l *liveReload) subscribe() chan event {
+	client := make(chan event, 4)
+	l.mu.Lock()
+	l.clients[client] = struct{}{}
+	l.mu.Unlock()
+	return client
+}
+
+func (l *liveReload) unsubscribe(client chan event) {
+	l.mu.Lock()
+	delete(l.clients, client)
+	l.mu.Unlock()
+}
+
+func (l *liveReload) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case liveReloadEvents:
+		l.serveEvents(res, req)
+	case liveReloadClient:
+		res.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		res.Header().Set("Cache-Control", "no-cache")
+		res.Write([]byte(jsLiveReload))
+	default:
+		http.NotFound(res, req)
+	}
+}
+
+func (l *liveReload) serveEvents(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		http.Error(res, "Streaming unsupported.", http.StatusInternalServerError)
+		return
+	}
+
+	client := l.subscribe()
+	defer l.unsubscribe(client)
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case e := <-client:
+			writeEvent(res, e)
+		case <-ping.C:
+			fmt.Fprint(res, ": ping\n\n")
+		case <-req.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(res http.ResponseWriter, e event) {
+	fmt.Fprintf(res, "event: %s\n", e.name)
+	for _, line := range strings.Split(e.data, "\n") {
+		fmt.Fprintf(res, "data: %s\n", line)
+	}
+	fmt.Fprint(res, "\n")
+}
+
+// injectLiveReload adds the live reload client to uncompressed html responses
+func injectLiveReload(res *http.Response) error {
+	if !canInject(res) {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	body = injectScript(body)
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+func canInject(res *http.Response) bool {
+	if res.Request != nil && res.Request.Method == http.MethodHead {
+		return false
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified {
+		return false
+	}
+	if encoding := res.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(res.Header.Get("Content-Type")), "text/html")
+}
+
+// injectScript places the client script before the closing body tag, or at
+// the end of the document when there is none
+func injectScript(body []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+	if i < 0 {
+		return append(body, liveReloadScript...)
+	}
+
+	injected := make([]byte, 0, len(body)+len(liveReloadScript))
+	injected = append(injected, body[:i]...)
+	injected = append(injected, liveReloadScript...)
+	return append(injected, body[i:]...)
+}
+
+var jsLiveReload = `
+(function () {
+  if (!window.EventSource) {
+    return;
+  }
+
+  var overlay;
+  var source = new EventSource("` + liveReloadEvents + `");
+
+  source.addEventListener("reload", function () {
+    window.location.reload();
+  });
+
+  source.addEventListener("failed", function (e) {
+    if (!overlay) {
+      overlay = document.createElement("pre");
+      overlay.style.cssText = "position:fixed;top:0;left:0;right:0;bottom:0;margin:0;padding:2em;" +
+        "overflow:auto;z-index:2147483647;background:rgba(20,20,20,.95);color:#f88;" +
+        "font:13px/1.5 monospace;white-space:pre-wrap;";
+      document.body.appendChild(overlay);
+    }
+    overlay.textContent = "Build Failed\n\n" + e.data;
+  });
+})();
+`
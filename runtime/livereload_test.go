@@ -0,0 +1,96 @@
+package runtime
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_InjectScript(t *testing.T) {
+	body := injectScript([]byte("<html><BODY><p>hi</p></BODY></html>"))
+	test.Expect(t, string(body), "<html><BODY><p>hi</p>"+liveReloadScript+"</BODY></html>")
+
+	body = injectScript([]byte("<p>fragment</p>"))
+	test.Expect(t, string(body), "<p>fragment</p>"+liveReloadScript)
+}
+
+func Test_LiveReload_Skips_Compressed(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		fmt.Fprint(w, "<body></body>")
+	}))
+	defer ts.Close()
+
+	config := &Config{
+		Port:       5682,
+		ProxyTo:    ts.URL,
+		LiveReload: true,
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	res, err := http.Get("http://localhost:5682/")
+	test.Expect(t, err, nil)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	test.Expect(t, string(body), "<body>"+liveReloadScript+"</body>")
+
+	req, _ := http.NewRequest("GET", "http://localhost:5682/gzip", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err = http.DefaultTransport.RoundTrip(req)
+	test.Expect(t, err, nil)
+	body, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	test.Expect(t, string(body), "<body></body>")
+}
+
+func Test_LiveReload_Events(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	config := &Config{
+		Port:       5683,
+		ProxyTo:    "http://localhost:3000",
+		LiveReload: true,
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	res, err := http.Get("http://localhost:5683" + liveReloadEvents)
+	test.Expect(t, err, nil)
+	defer res.Body.Close()
+	test.Expect(t, res.Header.Get("Content-Type"), "text/event-stream")
+	test.Expect(t, runner.DidRun, false)
+
+	proxy.Reload(errors.New("main.go:1: oops\nmore"))
+
+	reader := bufio.NewReader(res.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Could not read event: %v", err)
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	test.Expect(t, strings.Join(lines, "|"), "event: failed|data: main.go:1: oops|data: more")
+}
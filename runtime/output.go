@@ -0,0 +1,56 @@
+package runtime
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// outputBuffer retains the most recent lines written by the app
+type outputBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	max     int
+	partial []byte
+}
+
+func newOutputBuffer(max int) *outputBuffer {
+	return &outputBuffer{max: max}
+}
+
+func (o *outputBuffer) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	data := append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		o.append(string(data[:i]))
+		data = data[i+1:]
+	}
+	o.partial = append([]byte(nil), data...)
+
+	return len(p), nil
+}
+
+func (o *outputBuffer) append(line string) {
+	o.lines = append(o.lines, strings.TrimSuffix(line, "\r"))
+	if len(o.lines) > o.max {
+		o.lines = o.lines[len(o.lines)-o.max:]
+	}
+}
+
+// String returns the retained output, including any unterminated last line
+func (o *outputBuffer) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	lines := o.lines
+	if len(o.partial) > 0 {
+		lines = append(lines[:len(lines):len(lines)], string(o.partial))
+	}
+	return strings.Join(lines, "\n")
+}
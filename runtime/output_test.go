@@ -0,0 +1,18 @@
+package runtime
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_OutputBuffer(t *testing.T) {
+	output := newOutputBuffer(2)
+
+	fmt.Fprint(output, "one\ntwo\r\nthr")
+	test.Expect(t, output.String(), "one\ntwo\nthr")
+
+	fmt.Fprint(output, "ee\nfour")
+	test.Expect(t, output.String(), "two\nthree\nfour")
+}
@@ -0,0 +1,117 @@
+package runtime
+
+import (
+	"html/template"
+)
+
+// page combines the shared layout with the content of a single page
+func page(content string) *template.Template {
+	layout := template.Must(template.New("layout").Parse(tplLayout))
+	return template.Must(layout.Parse(content))
+}
+
+var (
+	errorPage      = page(tplError)
+	notStartedPage = page(tplNotStarted)
+	crashPage      = page(tplCrash)
+)
+
+// tplLayout is self-contained so that pages render without network access
+var tplLayout = `
+<!DOCTYPE HTML>
+<html>
+  <head>
+    <title>{{ template "title" . }}</title>
+    <meta charset="utf-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1, user-scalable=no" />
+    <style>
+      * { box-sizing: border-box; }
+      body { margin: 0; background: #f5f5f5; color: #222; font: 15px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; }
+      nav { background: #222; color: #fff; padding: 12px 24px; font-size: 18px; }
+      main { max-width: 1100px; margin: 24px auto; padding: 0 24px; }
+      h1 { margin: 0 0 8px; font-size: 28px; color: #b52a2a; }
+      h2 { margin: 32px 0 8px; font-size: 18px; font-family: Menlo, Consolas, monospace; }
+      h3 { margin: 16px 0 8px; font-size: 15px; }
+      a { color: #2a62b5; }
+      pre, code { font-family: Menlo, Consolas, monospace; font-size: 13px; }
+      pre { margin: 0; padding: 12px; overflow-x: auto; background: #fff; border: 1px solid #ddd; border-radius: 4px; white-space: pre-wrap; }
+      .summary { padding: 12px 16px; background: #fbeaea; border-left: 4px solid #b52a2a; white-space: pre-wrap; }
+      .problem { margin: 12px 0 20px; }
+      .message { font-family: Menlo, Consolas, monospace; font-size: 13px; white-space: pre-wrap; }
+      .location { color: #666; font-size: 13px; }
+      .source { margin-top: 6px; padding: 0; background: #fff; border: 1px solid #ddd; border-radius: 4px; overflow-x: auto; }
+      .source div { padding: 0 12px; white-space: pre; font-family: Menlo, Consolas, monospace; font-size: 13px; }
+      .source .current { background: #fbeaea; }
+      .source span { display: inline-block; min-width: 48px; color: #999; user-select: none; }
+      ol { padding-left: 20px; }
+      li { margin-bottom: 6px; }
+      details { margin-top: 32px; }
+      summary { cursor: pointer; font-weight: bold; }
+    </style>
+  </head>
+  <body>
+    <nav>&#10227; reload</nav>
+    <main>
+      {{ template "content" . }}
+    </main>
+  </body>
+</html>
+`
+
+var tplError = `
+{{ define "title" }}Build Error{{ end }}
+{{ define "content" }}
+<h1>&#10006; Build Failed</h1>
+{{ range .Other }}<div class="summary">{{ .Message }}</div>{{ end }}
+{{ range .Files }}
+<h2>{{ .File }}</h2>
+{{ range .Problems }}
+<div class="problem">
+  <div class="message">{{ .Message }}</div>
+  <div class="location">
+    {{ if .Link }}<a href="{{ .Link }}">{{ .File }}:{{ .Line }}{{ if .Column }}:{{ .Column }}{{ end }}</a>{{ else }}{{ .File }}:{{ .Line }}{{ if .Column }}:{{ .Column }}{{ end }}{{ end }}
+  </div>
+  {{ if .Excerpt }}
+  <div class="source">{{ range .Excerpt }}<div{{ if .Current }} class="current"{{ end }}><span>{{ .Number }}</span>{{ .Text }}</div>{{ end }}</div>
+  {{ end }}
+</div>
+{{ end }}
+{{ end }}
+<details{{ if not .Files }}{{ if not .Other }} open{{ end }}{{ end }}>
+  <summary>Compiler output</summary>
+  <pre>{{ .Output }}</pre>
+</details>
+{{ end }}
+`
+
+var tplNotStarted = `
+{{ define "title" }}App Did Not Start{{ end }}
+{{ define "content" }}
+<h1>&#10006; App Did Not Start</h1>
+<div class="summary">{{ .Error }}</div>
+{{ if .Output }}<h3>Output</h3><pre>{{ .Output }}</pre>{{ end }}
+{{ end }}
+`
+
+var tplCrash = `
+{{ define "title" }}Process Crashed{{ end }}
+{{ define "content" }}
+<h1>&#10006; Process Crashed</h1>
+<div class="summary">{{ .Status }}{{ if ge .Code 0 }} (exit code {{ .Code }}){{ end }}</div>
+{{ with .Panic }}
+<h2>{{ .Message }}</h2>
+{{ range .Goroutines }}
+<h3>{{ .Header }}</h3>
+<ol>
+  {{ range .Frames }}
+  <li>
+    <code>{{ .Func }}</code>
+    {{ if .File }}<div class="location">{{ if .Link }}<a href="{{ .Link }}">{{ .File }}:{{ .Line }}</a>{{ else }}{{ .File }}:{{ .Line }}{{ end }}</div>{{ end }}
+  </li>
+  {{ end }}
+</ol>
+{{ end }}
+{{ end }}
+{{ if .Output }}<h3>Output</h3><pre>{{ .Output }}</pre>{{ end }}
+{{ end }}
+`
@@ -0,0 +1,38 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// groupExited reports whether every process in the group led by process
+// has exited. Exited processes that were orphaned count as gone even if
+// nothing reaps them, as happens in containers without an init process.
+func groupExited(process *os.Process) bool {
+	if syscall.Kill(-process.Pid, 0) == syscall.ESRCH {
+		return true
+	}
+
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil || len(stats) == 0 {
+		return false
+	}
+	group := strconv.Itoa(process.Pid)
+	for _, file := range stats {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		// the fields following the command name, which may contain spaces:
+		// state, parent pid and process group
+		fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+		if len(fields) >= 3 && fields[2] == group && fields[0] != "Z" {
+			return false
+		}
+	}
+	return true
+}
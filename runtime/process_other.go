@@ -0,0 +1,15 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package runtime
+
+import (
+	"os"
+	"syscall"
+)
+
+// groupExited reports whether every process in the group led by process
+// has exited
+func groupExited(process *os.Process) bool {
+	return syscall.Kill(-process.Pid, 0) == syscall.ESRCH
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package runtime
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in a process group of its own, so that
+// the processes it spawns can be stopped along with it
+func setProcessGroup(command *exec.Cmd) {
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalGroup sends sig to every process in the group led by process
+func signalGroup(process *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return process.Signal(sig)
+	}
+	return syscall.Kill(-process.Pid, s)
+}
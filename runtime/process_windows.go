@@ -0,0 +1,24 @@
+package runtime
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op, as processes are stopped one at a time on
+// windows
+func setProcessGroup(command *exec.Cmd) {
+}
+
+// signalGroup sends sig to the process only
+func signalGroup(process *os.Process, sig os.Signal) error {
+	if sig == os.Kill {
+		return process.Kill()
+	}
+	return process.Signal(sig)
+}
+
+// groupExited reports true, as only the process itself is waited for
+func groupExited(process *os.Process) bool {
+	return true
+}
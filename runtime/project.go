@@ -0,0 +1,264 @@
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// templateDirs hold templates the app usually parses when it starts
+var templateDirs = []string{"templates", "views"}
+
+// assetDirs hold files the app usually serves as they are
+var assetDirs = []string{"static", "public", "assets"}
+
+// certificates are well-known names of certificate and key pairs, as
+// created by tools such as mkcert or openssl
+var certificates = [][2]string{
+	{"cert.pem", "key.pem"},
+	{"server.crt", "server.key"},
+	{"localhost.pem", "localhost-key.pem"},
+}
+
+// Project describes what was found when inspecting a project directory
+type Project struct {
+	// Module is the module path from go.mod
+	Module string
+	// Main is the directory of the main package, relative to the project
+	Main string
+	// Env reports whether the project has a .env file
+	Env bool
+	// Templates and Assets are directories of files that are not embedded
+	// into the binary, relative to the project
+	Templates []string
+	Assets    []string
+	// CertFile and KeyFile are an existing certificate and key pair,
+	// relative to the project
+	CertFile string
+	KeyFile  string
+}
+
+// InspectProject looks for the main package, the module, the environment,
+// asset directories and certificates of the project in dir
+func InspectProject(dir string) (*Project, error) {
+	p := new(Project)
+
+	if module, err := modulePath(filepath.Join(dir, "go.mod")); err == nil {
+		p.Module = module
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	p.Main = mainPackage(dir)
+	p.Env = exists(filepath.Join(dir, ".env"))
+
+	var embedded func(string) bool
+	if deps, err := LoadDeps(context.Background(), filepath.Join(dir, p.Main), nil); err == nil {
+		embedded = deps.Embeds
+	}
+	p.Templates = resourceDirs(dir, templateDirs, embedded)
+	p.Assets = resourceDirs(dir, assetDirs, embedded)
+
+	for _, sub := range []string{".", "certs", "tls"} {
+		for _, pair := range certificates {
+			cert, key := filepath.Join(sub, pair[0]), filepath.Join(sub, pair[1])
+			if p.CertFile == "" && exists(filepath.Join(dir, cert)) && exists(filepath.Join(dir, key)) {
+				p.CertFile, p.KeyFile = cert, key
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// Configure applies the findings to config: the main package is built,
+// templates restart the app and assets refresh the browser
+func (p *Project) Configure(config *Config) {
+	if p.Main != "" {
+		config.Build = p.Main
+	}
+	for _, dir := range p.Templates {
+		config.Rules = append(config.Rules, ActionRule{Pattern: dir + "/**", Action: ActionRestart})
+	}
+	for _, dir := range p.Assets {
+		config.Rules = append(config.Rules, ActionRule{Pattern: dir + "/**", Action: ActionRefresh})
+	}
+	if len(p.Assets) > 0 {
+		config.LiveReload = true
+	}
+	if p.CertFile != "" {
+		config.CertFile, config.KeyFile = p.CertFile, p.KeyFile
+	}
+}
+
+// configSetting is a documented entry of a generated configuration file
+type configSetting struct {
+	comment string
+	key     string
+	value   interface{}
+}
+
+// WriteConfig writes the settings of config a project usually changes as a
+// configuration file, in the order of the flags. The file stays plain JSON:
+// each setting is documented by a key starting with //, which Merge ignores.
+func (p *Project) WriteConfig(w io.Writer, config *Config) error {
+	settings := []configSetting{
+		{"port the proxy listens on", "port", config.Port},
+		{"port passed to the app in the PORT environment variable", "app_port", config.AppPort},
+		{"directory watched for changes, relative to this file", "path", config.Path},
+		{"directory of the main package, relative to this file", "build", config.Build},
+		{"additional go build arguments, e.g. \"-tags dev\"", "build_args", config.BuildArgs},
+		{"name of the binary built in the working directory", "bin", config.Bin},
+		{"start the app after each build instead of on the next request", "immediate", config.Immediate},
+		{"refresh open browser tabs after each build", "live_reload", config.LiveReload},
+		{"directories, relative to this file, that are never watched", "exclude_dirs", nonNil(config.ExcludeDirs)},
+		{"actions taken instead of rebuilding: restart, refresh or signal:NAME", "rules", nonNilRules(config.Rules)},
+	}
+	if config.CertFile != "" {
+		settings = append(settings,
+			configSetting{"certificate served by the proxy, relative to this file", "cert_file", config.CertFile},
+			configSetting{"key of the certificate, relative to this file", "key_file", config.KeyFile})
+	}
+	settings = append(settings, configSetting{"desktop notifications for build results", "notifications", config.Notifications})
+
+	header := "reload configuration"
+	if p.Module != "" {
+		header += " for " + p.Module
+	}
+	header += ". Keys starting with // are comments. Command line flags and RELOAD_* environment variables take precedence over these settings."
+	if p.Env {
+		header += " The variables in .env are added to the app's environment."
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "{")
+	if err := writeEntry(out, "//", header, ","); err != nil {
+		return err
+	}
+	for i, setting := range settings {
+		separator := ","
+		if i == len(settings)-1 {
+			separator = ""
+		}
+		if err := writeEntry(out, "//"+setting.key, setting.comment, ","); err != nil {
+			return err
+		}
+		if err := writeEntry(out, setting.key, setting.value, separator); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(out, "}")
+
+	return out.Flush()
+}
+
+// writeEntry writes a key and its indented value as a line of a JSON object
+func writeEntry(out io.Writer, key string, value interface{}, separator string) error {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	v, err := json.MarshalIndent(value, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "  %s: %s%s\n", k, v, separator)
+	return err
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func nonNilRules(rules []ActionRule) []ActionRule {
+	if rules == nil {
+		return []ActionRule{}
+	}
+	return rules
+}
+
+// modulePath reads the module path from a go.mod file
+func modulePath(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module path in %s", file)
+}
+
+// mainPackage returns the directory of the main package, preferring the
+// project directory itself and then the first of cmd/*
+func mainPackage(dir string) string {
+	if isMain(dir) {
+		return "."
+	}
+
+	cmds, _ := filepath.Glob(filepath.Join(dir, "cmd", "*"))
+	sort.Strings(cmds)
+	for _, cmd := range cmds {
+		if isMain(cmd) {
+			return filepath.ToSlash(filepath.Join("cmd", filepath.Base(cmd)))
+		}
+	}
+	return ""
+}
+
+func isMain(dir string) bool {
+	pkg, err := build.ImportDir(dir, 0)
+	return err == nil && pkg.Name == "main"
+}
+
+// errEmbeds stops the walk of a directory at its first embedded file
+var errEmbeds = errors.New("embeds files")
+
+// resourceDirs returns the candidate directories that exist in dir, skipping
+// those with files embedded into the binary, which rebuild on their own
+func resourceDirs(dir string, candidates []string, embedded func(string) bool) []string {
+	var found []string
+	for _, name := range candidates {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+
+		embeds := false
+		if embedded != nil {
+			filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() && embedded(file) {
+					embeds = true
+					return errEmbeds
+				}
+				return nil
+			})
+		}
+		if !embeds {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
@@ -0,0 +1,102 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_InspectProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	writeFiles(t, map[string]string{
+		filepath.Join(dir, "go.mod"):                     "module example.com/app\n\ngo 1.16\n",
+		filepath.Join(dir, ".env"):                       "DEBUG=1\n",
+		filepath.Join(dir, "main.go"):                    "package main\n\nimport \"embed\"\n\n//go:embed public\nvar public embed.FS\n\nfunc main() {}\n",
+		filepath.Join(dir, "public", "app.js"):           "\n",
+		filepath.Join(dir, "templates", "index.html"):    "<html></html>\n",
+		filepath.Join(dir, "static", "app.css"):          "body {}\n",
+		filepath.Join(dir, "certs", "localhost.pem"):     "\n",
+		filepath.Join(dir, "certs", "localhost-key.pem"): "\n",
+	})
+
+	project, err := InspectProject(dir)
+	test.Expect(t, err, nil)
+	test.Expect(t, project.Module, "example.com/app")
+	test.Expect(t, project.Main, ".")
+	test.Expect(t, project.Env, true)
+	test.Expect(t, strings.Join(project.Templates, ","), "templates")
+	test.Expect(t, strings.Join(project.Assets, ","), "static")
+	test.Expect(t, project.CertFile, filepath.Join("certs", "localhost.pem"))
+	test.Expect(t, project.KeyFile, filepath.Join("certs", "localhost-key.pem"))
+}
+
+func Test_mainPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, map[string]string{
+		filepath.Join(dir, "lib.go"):                   "package app\n",
+		filepath.Join(dir, "cmd", "migrate", "lib.go"): "package migrate\n",
+		filepath.Join(dir, "cmd", "server", "main.go"): "package main\n\nfunc main() {}\n",
+	})
+
+	test.Expect(t, mainPackage(dir), "cmd/server")
+	test.Expect(t, mainPackage(filepath.Join(dir, "cmd", "migrate")), "")
+}
+
+func Test_Project_WriteConfig(t *testing.T) {
+	project := &Project{
+		Module:    "example.com/app",
+		Main:      "cmd/server",
+		Templates: []string{"templates"},
+		Assets:    []string{"static"},
+		Env:       true,
+	}
+	config := &Config{Port: 3000, AppPort: 3001, Path: ".", Bin: "reload-bin"}
+	project.Configure(config)
+
+	var out bytes.Buffer
+	test.Expect(t, project.WriteConfig(&out, config), nil)
+	test.Expect(t, json.Valid(out.Bytes()), true)
+
+	var keys map[string]interface{}
+	test.Expect(t, json.Unmarshal(out.Bytes(), &keys), nil)
+	test.Expect(t, strings.HasPrefix(keys["//"].(string), "reload configuration for example.com/app."), true)
+	test.Expect(t, strings.Contains(keys["//"].(string), ".env"), true)
+	test.Expect(t, keys["//port"], "port the proxy listens on")
+
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ConfigFile)
+	if err := ioutil.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatalf("Could not write %s: %v", path, err)
+	}
+
+	read, err := LoadConfig(path)
+	test.Expect(t, err, nil)
+	test.Expect(t, read.Port, 3000)
+	test.Expect(t, read.Build, filepath.Join(dir, "cmd", "server"))
+	test.Expect(t, read.LiveReload, true)
+	test.Expect(t, len(read.Rules), 2)
+	test.Expect(t, read.Rules[0], ActionRule{Pattern: "templates/**", Action: ActionRestart})
+	test.Expect(t, read.Rules[1], ActionRule{Pattern: "static/**", Action: ActionRefresh})
+}
@@ -1,21 +1,42 @@
 package runtime
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"html/template"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"os/exec"
 	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Proxy provides a web server proxy
 type Proxy interface {
 	// Run bootstraps the web service proxy
 	Run(config *Config) error
+	// Reload signals connected browsers to refresh, or to display the build
+	// error when err is not nil
+	Reload(err error)
+	// Disconnect closes the long-lived connections, such as websockets and
+	// event streams, opened to the app so far, ahead of stopping it
+	Disconnect()
+	// Swap waits for the app started by command to accept requests at to,
+	// unless done is closed or ctx is cancelled first, and then forwards new
+	// requests there, disconnecting the long-lived connections to the
+	// previous apps
+	Swap(ctx context.Context, to *url.URL, command *exec.Cmd, done <-chan struct{}) error
 	io.Closer
 }
 
@@ -24,14 +45,41 @@ type proxy struct {
 	proxy    *httputil.ReverseProxy
 	builder  Builder
 	runner   Runner
+	state    *BuildState
+	mu       sync.RWMutex
 	to       *url.URL
+	swapped  *exec.Cmd
+	wait     time.Duration
+	live     *liveReload
+	inject   bool
+	ready    readiness
+	editor   string
+	tunnels  *tunnels
+}
+
+// crashWait is how long a failed request waits to learn whether the app crashed
+const crashWait = 250 * time.Millisecond
+
+// readiness remembers which process has been confirmed to accept requests
+type readiness struct {
+	sync.Mutex
+	path    string
+	timeout time.Duration
+	command *exec.Cmd
 }
 
 // NewProxy constructs a new Proxy
-func NewProxy(builder Builder, runner Runner) Proxy {
+func NewProxy(builder Builder, runner Runner, state *BuildState) Proxy {
+	if state == nil {
+		state = NewBuildState()
+	}
+
 	return &proxy{
 		builder: builder,
 		runner:  runner,
+		state:   state,
+		live:    newLiveReload(),
+		tunnels: newTunnels(),
 	}
 }
 
@@ -42,9 +90,28 @@ func (p *proxy) Run(config *Config) error {
 		return err
 	}
 	p.proxy = httputil.NewSingleHostReverseProxy(url)
+	director := p.proxy.Director
+	p.proxy.Director = func(req *http.Request) {
+		director(req)
+		// the app may have been swapped for one listening elsewhere
+		req.URL.Host = p.target().Host
+		forwarded(req)
+	}
+	p.proxy.Transport = newBackendTransport(config.H2C)
+	// streamed responses, such as gRPC calls, are passed on as they arrive
+	p.proxy.FlushInterval = -1
+	p.proxy.ErrorHandler = p.proxyError
 	p.to = url
+	p.wait = time.Duration(config.BuildWait)
+	p.inject = config.LiveReload
+	p.editor = config.Editor
+	p.ready.path = config.HealthPath
+	p.ready.timeout = time.Duration(config.ReadyTimeout)
+	if p.inject {
+		p.proxy.ModifyResponse = injectLiveReload
+	}
 
-	server := http.Server{Handler: http.HandlerFunc(p.defaultHandler)}
+	server := &http.Server{Handler: http.HandlerFunc(p.defaultHandler)}
 
 	if config.CertFile != "" && config.KeyFile != "" {
 		cer, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
@@ -52,13 +119,20 @@ func (p *proxy) Run(config *Config) error {
 			return err
 		}
 
+		// clients negotiate HTTP/2 while establishing the TLS connection
 		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cer}}
+		if err := http2.ConfigureServer(server, nil); err != nil {
+			return err
+		}
 
 		p.listener, err = tls.Listen("tcp", fmt.Sprintf("%s:%d", config.Laddr, config.Port), server.TLSConfig)
 		if err != nil {
 			return err
 		}
 	} else {
+		// cleartext clients use HTTP/2 with prior knowledge or an upgrade
+		server.Handler = h2c.NewHandler(server.Handler, &http2.Server{})
+
 		p.listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", config.Laddr, config.Port))
 		if err != nil {
 			return err
@@ -74,88 +148,216 @@ func (p *proxy) Close() error {
 	return p.listener.Close()
 }
 
+func (p *proxy) Reload(err error) {
+	if err != nil {
+		p.live.publish("failed", err.Error())
+	} else {
+		p.live.publish("reload", "")
+	}
+}
+
+func (p *proxy) Swap(ctx context.Context, to *url.URL, command *exec.Cmd, done <-chan struct{}) error {
+	exited := func() bool {
+		select {
+		case <-done:
+			return true
+		case <-ctx.Done():
+			return true
+		default:
+			return false
+		}
+	}
+
+	// requests keep going to the previous app in the meantime
+	if p.ready.timeout > 0 {
+		if err := waitReady(to, p.ready.path, p.ready.timeout, exited); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+	}
+
+	p.ready.Lock()
+	p.ready.command = command
+	p.mu.Lock()
+	p.to = to
+	p.swapped = command
+	p.mu.Unlock()
+	p.ready.Unlock()
+
+	if closed := p.tunnels.close(p.tunnels.started(command)); closed > 0 {
+		log.Printf("closed %d connection(s) to the previous app", closed)
+	}
+	return nil
+}
+
+// target returns the address of the app requests are forwarded to
+func (p *proxy) target() *url.URL {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.to
+}
+
+// app returns the process requests are forwarded to: the one swapped in, or
+// else the one the runner started
+func (p *proxy) app(started *exec.Cmd) *exec.Cmd {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.swapped != nil {
+		return p.swapped
+	}
+	return started
+}
+
+func (p *proxy) Disconnect() {
+	if closed := p.tunnels.close(p.tunnels.current()); closed > 0 {
+		log.Printf("closed %d connection(s) to the app", closed)
+	}
+}
+
 func (p *proxy) defaultHandler(res http.ResponseWriter, req *http.Request) {
+	if p.inject && strings.HasPrefix(req.URL.Path, liveReloadPrefix) {
+		p.live.ServeHTTP(res, req)
+		return
+	}
+
+	// hold the request until the in-flight build, if any, completes
+	if !p.state.Wait(p.wait) {
+		res.Header().Set("Retry-After", "1")
+		http.Error(res, "Build in progress, please retry.", http.StatusServiceUnavailable)
+		return
+	}
+
 	errors := p.builder.Errors()
 	if len(errors) > 0 {
-		t := template.Must(template.New("errors").Parse(tplError))
-		safe := template.HTMLEscapeString(errors)
-		safe = strings.Replace(safe, "\n", "<br>", -1)
-		if err := t.Execute(res, template.HTML(safe)); err != nil {
-			res.Write([]byte(errors))
-		}
+		report := newErrorReport(errors, p.builder.Diagnostics(), p.builder.Dir(), p.editor)
+		p.render(res, http.StatusOK, errorPage, report, errors)
 	} else {
-		p.runner.Run()
-		if strings.ToLower(req.Header.Get("Upgrade")) == "websocket" || strings.ToLower(req.Header.Get("Accept")) == "text/event-stream" {
-			proxyWebsocket(res, req, p.to)
+		command, err := p.runner.Run()
+		if err == nil {
+			err = p.waitReady(command)
+		}
+		if state := p.runner.ProcessState(); state != nil && !state.Success() {
+			p.renderCrash(res, state)
+			return
+		}
+		if err != nil {
+			output := p.runner.Output()
+			data := map[string]string{"Error": err.Error(), "Output": output}
+			p.render(res, http.StatusBadGateway, notStartedPage, data, err.Error()+"\n"+output)
+			return
+		}
+
+		// upgrades such as websockets are tunnelled, and event streams are
+		// flushed as they arrive
+		if longLived(req) {
+			p.serveTunnel(res, req, p.app(command))
 		} else {
 			p.proxy.ServeHTTP(res, req)
 		}
 	}
 }
 
-func proxyWebsocket(w http.ResponseWriter, r *http.Request, host *url.URL) {
-	d, err := net.Dial("tcp", host.Host)
-	if err != nil {
-		http.Error(w, "Error contacting backend server.", 500)
-		fmt.Errorf("Error dialing websocket backend %s: %v", host, err)
-		return
+// serveTunnel proxies a long-lived request to the process started by
+// command, keeping track of it so that it can be closed when the app restarts
+func (p *proxy) serveTunnel(res http.ResponseWriter, req *http.Request, command *exec.Cmd) {
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	t := &tunnel{cancel: cancel, websocket: strings.EqualFold(req.Header.Get("Upgrade"), "websocket")}
+	p.tunnels.add(t, command)
+	defer p.tunnels.remove(t)
+
+	p.proxy.ServeHTTP(&tunnelWriter{ResponseWriter: res, tunnel: t}, req.WithContext(ctx))
+}
+
+// forwarded tells the app the host and scheme the client requested, next to
+// the client address set by the reverse proxy
+func forwarded(req *http.Request) {
+	if req.Header.Get("X-Forwarded-Host") == "" {
+		req.Header.Set("X-Forwarded-Host", req.Host)
 	}
-	hj, ok := w.(http.Hijacker)
-	if !ok {
-		http.Error(w, "Not a hijacker?", 500)
-		return
+	if req.Header.Get("X-Forwarded-Proto") == "" {
+		if req.TLS != nil {
+			req.Header.Set("X-Forwarded-Proto", "https")
+		} else {
+			req.Header.Set("X-Forwarded-Proto", "http")
+		}
 	}
-	nc, _, err := hj.Hijack()
-	if err != nil {
-		fmt.Errorf("Hijack error: %v", err)
+}
+
+// waitReady blocks until the given process is accepting requests; processes
+// already confirmed to be ready are not checked again
+func (p *proxy) waitReady(command *exec.Cmd) error {
+	p.ready.Lock()
+	confirmed := p.ready.timeout <= 0 || (command != nil && command == p.ready.command)
+	p.ready.Unlock()
+	if confirmed {
+		return nil
+	}
+
+	// concurrent requests probe on their own rather than queue behind one
+	exited := func() bool { return p.runner.ProcessState() != nil }
+	if err := waitReady(p.target(), p.ready.path, p.ready.timeout, exited); err != nil {
+		return err
+	}
+
+	p.ready.Lock()
+	p.ready.command = command
+	p.ready.Unlock()
+	return nil
+}
+
+// proxyError handles failures to reach the app, showing the crash page when
+// the request failed because the app went down
+func (p *proxy) proxyError(res http.ResponseWriter, req *http.Request, err error) {
+	if req.Context().Err() != nil {
+		// the client went away
 		return
 	}
-	defer nc.Close()
-	defer d.Close()
 
-	err = r.Write(d)
-	if err != nil {
-		fmt.Errorf("Error copying request to target: %v", err)
+	// give the runner a moment to observe the exit of a crashing process
+	deadline := time.Now().Add(crashWait)
+	for {
+		if state := p.runner.ProcessState(); state != nil {
+			if !state.Success() {
+				p.renderCrash(res, state)
+				return
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	log.Printf("proxy error: %v", err)
+	res.WriteHeader(http.StatusBadGateway)
+}
+
+func (p *proxy) renderCrash(res http.ResponseWriter, state *os.ProcessState) {
+	output := p.runner.Output()
+	report := newCrashReport(state, output, p.editor)
+	p.render(res, http.StatusBadGateway, crashPage, report, state.String()+"\n"+output)
+}
+
+// render writes one of the proxy's own pages, falling back to plain text if
+// the template cannot be executed
+func (p *proxy) render(res http.ResponseWriter, status int, t *template.Template, data interface{}, fallback string) {
+	var page bytes.Buffer
+	if err := t.Execute(&page, data); err != nil {
+		res.WriteHeader(status)
+		res.Write([]byte(fallback))
 		return
 	}
 
-	errc := make(chan error, 2)
-	cp := func(dst io.Writer, src io.Reader) {
-		_, err := io.Copy(dst, src)
-		errc <- err
-	}
-	go cp(d, nc)
-	go cp(nc, d)
-	<-errc
-}
-
-var tplError = `
-<!DOCTYPE HTML>
-<html>
-  <head>
-    <title>Build Error</title>
-    <meta charset="utf-8" />
-    <meta name="viewport" content="width=device-width, initial-scale=1, user-scalable=no" />
-    <link rel="stylesheet" href="https://use.fontawesome.com/releases/v5.1.0/css/all.css" />
-    <link rel="stylesheet" href="//maxcdn.bootstrapcdn.com/bootstrap/3.3.7/css/bootstrap.min.css" />
-  </head>
-  <body>
-    <nav class="navbar navbar-inverse navbar-static-top">
-      <div class="container-fluid">
-        <div class="navbar-header">
-          <a class="navbar-brand" href="#"> <i class="fas fa-sync-alt"></i> reload</a>
-        </div>
-      </div>
-    </nav>
-    <br/>
-    <div class="container">
-      <div class="jumbotron">
-        <h1><i class="fa fa-exclamation-circle text-danger"></i> Build Failed</h1>
-        <p class="bg-danger">
-          {{ . }}
-        </p>
-      </div>
-    </div>
-  </body>
-</html>
-`
+	body := page.Bytes()
+	if p.inject {
+		body = injectScript(body)
+	}
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(status)
+	res.Write(body)
+}
@@ -1,28 +1,41 @@
 package runtime
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	"github.com/n3integration/reload/test"
 )
 
 func Test_NewProxy(t *testing.T) {
-	builder := test.NewMockBuilder()
+	builder := newMockBuilder()
 	runner := test.NewMockRunner()
-	proxy := NewProxy(builder, runner)
+	proxy := NewProxy(builder, runner, NewBuildState())
 
 	test.Expect(t, proxy != nil, true)
 }
 
 func Test_Proxy_Run(t *testing.T) {
-	builder := test.NewMockBuilder()
+	builder := newMockBuilder()
 	runner := test.NewMockRunner()
-	proxy := NewProxy(builder, runner)
+	proxy := NewProxy(builder, runner, NewBuildState())
 
 	config := &Config{}
 
@@ -31,9 +44,9 @@ func Test_Proxy_Run(t *testing.T) {
 }
 
 func Test_Proxying(t *testing.T) {
-	builder := test.NewMockBuilder()
+	builder := newMockBuilder()
 	runner := test.NewMockRunner()
-	proxy := NewProxy(builder, runner)
+	proxy := NewProxy(builder, runner, NewBuildState())
 
 	// create a test server and see if we can proxy a request
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -60,9 +73,9 @@ func Test_Proxying(t *testing.T) {
 }
 
 func Test_Proxying_Websocket(t *testing.T) {
-	builder := test.NewMockBuilder()
+	builder := newMockBuilder()
 	runner := test.NewMockRunner()
-	proxy := NewProxy(builder, runner)
+	proxy := NewProxy(builder, runner, NewBuildState())
 
 	// create a test server and see if we can proxy a websocket request
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -93,10 +106,10 @@ func Test_Proxying_Websocket(t *testing.T) {
 }
 
 func Test_Proxying_Build_Errors(t *testing.T) {
-	builder := test.NewMockBuilder()
+	builder := newMockBuilder()
 	builder.MockErrors = "Foo bar here are some errors"
 	runner := test.NewMockRunner()
-	proxy := NewProxy(builder, runner)
+	proxy := NewProxy(builder, runner, NewBuildState())
 
 	config := &Config{
 		Port:    5679,
@@ -113,5 +126,350 @@ func Test_Proxying_Build_Errors(t *testing.T) {
 	errors, err := ioutil.ReadAll(res.Body)
 	res.Body.Close()
 	test.Expect(t, strings.Contains(fmt.Sprintf("%s", errors), builder.MockErrors), true)
+	test.Expect(t, strings.Contains(fmt.Sprintf("%s", errors), "://"), false)
 	//test.Expect(t, strings.Contains(builder.MockErrors, fmt.Sprintf("%s", errors), "Foo bar here are some errors")
 }
+
+func Test_Proxying_Waits_For_Build(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	state := NewBuildState()
+	proxy := NewProxy(builder, runner, state)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Hello world")
+	}))
+	defer ts.Close()
+
+	config := &Config{
+		Port:      5680,
+		ProxyTo:   ts.URL,
+		BuildWait: Duration(5 * time.Second),
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	state.Begin()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		test.Expect(t, runner.DidRun, false)
+		state.End(nil)
+	}()
+
+	res, err := http.Get("http://localhost:5680")
+	test.Expect(t, err, nil)
+	greeting, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	test.Expect(t, fmt.Sprintf("%s", greeting), "Hello world\n")
+	test.Expect(t, runner.DidRun, true)
+}
+
+func Test_Proxying_Build_Wait_Timeout(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	state := NewBuildState()
+	proxy := NewProxy(builder, runner, state)
+
+	config := &Config{
+		Port:      5681,
+		ProxyTo:   "http://localhost:3000",
+		BuildWait: Duration(10 * time.Millisecond),
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	state.Begin()
+	defer state.End(nil)
+
+	res, err := http.Get("http://localhost:5681")
+	test.Expect(t, err, nil)
+	res.Body.Close()
+	test.Expect(t, res.StatusCode, http.StatusServiceUnavailable)
+	test.Expect(t, runner.DidRun, false)
+}
+
+func Test_Proxying_App_Not_Ready(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	runner.MockOutput = "listening on the wrong port"
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	config := &Config{
+		Port:         5684,
+		ProxyTo:      "http://localhost:5698",
+		ReadyTimeout: Duration(50 * time.Millisecond),
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	res, err := http.Get("http://localhost:5684")
+	test.Expect(t, err, nil)
+	page, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	test.Expect(t, res.StatusCode, http.StatusBadGateway)
+	test.Expect(t, strings.Contains(string(page), "App Did Not Start"), true)
+	test.Expect(t, strings.Contains(string(page), runner.MockOutput), true)
+}
+
+func Test_Proxying_Crashed_App(t *testing.T) {
+	bin := filepath.Join("testdata", "crashing")
+	if goruntime.GOOS == "windows" {
+		bin += ".bat"
+	}
+
+	builder := newMockBuilder()
+	runner := NewRunner(bin, NewBuildState())
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	config := &Config{
+		Port:         5685,
+		ProxyTo:      "http://localhost:5698",
+		ReadyTimeout: Duration(5 * time.Second),
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	res, err := http.Get("http://localhost:5685")
+	test.Expect(t, err, nil)
+	page, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	test.Expect(t, res.StatusCode, http.StatusBadGateway)
+	test.Expect(t, strings.Contains(string(page), "Process Crashed"), true)
+	test.Expect(t, strings.Contains(string(page), "exit code 2"), true)
+	test.Expect(t, strings.Contains(string(page), "panic: boom"), true)
+	test.Expect(t, strings.Contains(string(page), "/src/app/main.go:12"), true)
+}
+
+func Test_Proxying_GRPC(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	// gRPC servers only speak HTTP/2 and send the call status as a trailer
+	ts := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Header().Set("Content-Type", "application/grpc")
+		fmt.Fprint(w, r.Proto)
+		w.Header().Set("Grpc-Status", "0")
+	}), &http2.Server{}))
+	defer ts.Close()
+
+	config := &Config{
+		Port:    5686,
+		ProxyTo: ts.URL,
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+	res, err := client.Post("http://localhost:5686/helloworld.Greeter/SayHello", "application/grpc", strings.NewReader(""))
+	test.Expect(t, err, nil)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	test.Expect(t, res.Proto, "HTTP/2.0")
+	test.Expect(t, string(body), "HTTP/2.0")
+	test.Expect(t, res.Trailer.Get("Grpc-Status"), "0")
+}
+
+func Test_Proxying_HTTP2(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	local, err := EnsureLocalCertificate(dir, []string{"localhost"})
+	test.Expect(t, err, nil)
+	ca, err := ioutil.ReadFile(local.CAFile)
+	test.Expect(t, err, nil)
+
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Hello world")
+	}))
+	defer ts.Close()
+
+	config := &Config{
+		Port:     5687,
+		ProxyTo:  ts.URL,
+		CertFile: local.CertFile,
+		KeyFile:  local.KeyFile,
+	}
+
+	err = proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+	res, err := client.Get("https://localhost:5687")
+	test.Expect(t, err, nil)
+	greeting, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	test.Expect(t, res.ProtoMajor, 2)
+	test.Expect(t, string(greeting), "Hello world\n")
+}
+
+func Test_Proxying_Upgrade(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	// an app serving TLS that echoes lines once the connection is upgraded
+	forwardedFor := make(chan string, 1)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedFor <- r.Header.Get("X-Forwarded-For") + " " + r.Header.Get("X-Forwarded-Host") + " " + r.Header.Get("X-Forwarded-Proto")
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		line, _ := rw.ReadString('\n')
+		rw.WriteString(line)
+		rw.Flush()
+	}))
+	defer ts.Close()
+
+	config := &Config{
+		Port:    5688,
+		ProxyTo: ts.URL,
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:5688")
+	test.Expect(t, err, nil)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprint(conn, "GET /echo HTTP/1.1\r\nHost: localhost:5688\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	test.Expect(t, err, nil)
+	test.Expect(t, res.StatusCode, http.StatusSwitchingProtocols)
+	test.Expect(t, <-forwardedFor, "127.0.0.1 localhost:5688 http")
+
+	fmt.Fprint(conn, "ping\n")
+	echo, err := reader.ReadString('\n')
+	test.Expect(t, err, nil)
+	test.Expect(t, echo, "ping\n")
+}
+
+func Test_Proxying_Event_Stream(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	// the stream stays open until the first event has reached the client
+	received := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	config := &Config{
+		Port:    5689,
+		ProxyTo: ts.URL,
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	req, _ := http.NewRequest("GET", "http://localhost:5689/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	test.Expect(t, err, nil)
+	defer res.Body.Close()
+
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	close(received)
+	test.Expect(t, err, nil)
+	test.Expect(t, line, "data: first\n")
+}
+
+func Test_Proxying_Swap(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	serve := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, name)
+		}))
+	}
+	blue, green := serve("blue"), serve("green")
+	defer blue.Close()
+	defer green.Close()
+
+	config := &Config{
+		Port:         5692,
+		ProxyTo:      blue.URL,
+		ReadyTimeout: Duration(time.Second),
+	}
+
+	err := proxy.Run(config)
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	get := func() string {
+		res, err := http.Get("http://localhost:5692")
+		test.Expect(t, err, nil)
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		return string(body)
+	}
+	test.Expect(t, get(), "blue")
+
+	// an app that exits before it is ready is never swapped in
+	exited := make(chan struct{})
+	close(exited)
+	gone, _ := url.Parse("http://127.0.0.1:1")
+	test.Expect(t, proxy.Swap(context.Background(), gone, nil, exited), errExited)
+	test.Expect(t, get(), "blue")
+
+	// nor is one superseded by a newer change while starting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	test.Expect(t, proxy.Swap(ctx, gone, nil, make(chan struct{})), context.Canceled)
+	test.Expect(t, get(), "blue")
+
+	to, _ := url.Parse(green.URL)
+	test.Expect(t, proxy.Swap(context.Background(), to, nil, make(chan struct{})), nil)
+	test.Expect(t, get(), "green")
+}
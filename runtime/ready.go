@@ -0,0 +1,66 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const readyInterval = 100 * time.Millisecond
+
+var errExited = errors.New("app exited before it was ready")
+
+// waitReady polls the app until it accepts connections, or answers the health
+// check when a path is given, returning an error if the app exits or the
+// timeout elapses first
+func waitReady(to *url.URL, path string, timeout time.Duration, exited func() bool) error {
+	client := &http.Client{
+		Timeout: time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := probe(client, to, path)
+		if err == nil {
+			return nil
+		}
+		if exited() {
+			return errExited
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("app did not become ready on %s within %s: %v", to.Host, timeout, err)
+		}
+		time.Sleep(readyInterval)
+	}
+}
+
+func probe(client *http.Client, to *url.URL, path string) error {
+	if path == "" {
+		conn, err := net.DialTimeout("tcp", to.Host, time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	health, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+	res, err := client.Get(to.ResolveReference(health).String())
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("health check %s returned %s", path, res.Status)
+	}
+	return nil
+}
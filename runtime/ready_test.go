@@ -0,0 +1,50 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_WaitReady(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	to, _ := url.Parse(ts.URL)
+
+	test.Expect(t, waitReady(to, "", time.Second, running), nil)
+	test.Expect(t, waitReady(to, "/healthz", time.Second, running), nil)
+
+	err := waitReady(to, "/", 50*time.Millisecond, running)
+	test.Refute(t, err, nil)
+	test.Expect(t, strings.Contains(err.Error(), "503"), true)
+}
+
+func Test_WaitReady_Timeout(t *testing.T) {
+	to, _ := url.Parse("http://localhost:5699")
+
+	start := time.Now()
+	err := waitReady(to, "", 200*time.Millisecond, running)
+	test.Refute(t, err, nil)
+	test.Expect(t, time.Since(start) >= 200*time.Millisecond, true)
+}
+
+func Test_WaitReady_Exited(t *testing.T) {
+	to, _ := url.Parse("http://localhost:5699")
+
+	err := waitReady(to, "", time.Minute, func() bool { return true })
+	test.Expect(t, err, errExited)
+}
+
+func running() bool {
+	return false
+}
@@ -0,0 +1,98 @@
+package runtime
+
+import (
+	"bufio"
+	"html/template"
+	"os"
+	"path/filepath"
+)
+
+// excerptContext is the number of source lines shown around a failing line
+const excerptContext = 3
+
+// errorReport is the build failure page grouped by source file
+type errorReport struct {
+	Files  []fileReport
+	Other  []Diagnostic
+	Output string
+}
+
+type fileReport struct {
+	File     string
+	Problems []problem
+}
+
+type problem struct {
+	Diagnostic
+	Link    template.URL
+	Excerpt []excerptLine
+}
+
+type excerptLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+func newErrorReport(output string, diagnostics []Diagnostic, dir string, editor string) *errorReport {
+	report := &errorReport{Output: output}
+	files := make(map[string]int)
+
+	for _, d := range diagnostics {
+		if d.File == "" {
+			report.Other = append(report.Other, d)
+			continue
+		}
+
+		path := resolve(dir, d.File)
+		i, ok := files[d.File]
+		if !ok {
+			i = len(report.Files)
+			files[d.File] = i
+			report.Files = append(report.Files, fileReport{File: d.File})
+		}
+
+		report.Files[i].Problems = append(report.Files[i].Problems, problem{
+			Diagnostic: d,
+			Link:       editorLink(editor, path, d.Line, d.Column),
+			Excerpt:    sourceExcerpt(path, d.Line),
+		})
+	}
+
+	return report
+}
+
+// resolve returns the absolute path of a file reported relative to dir
+func resolve(dir string, file string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
+
+// sourceExcerpt reads the lines surrounding line from file, returning nil if
+// the file cannot be read
+func sourceExcerpt(file string, line int) []excerptLine {
+	if line < 1 {
+		return nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var excerpt []excerptLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan() && n <= line+excerptContext; n++ {
+		if n >= line-excerptContext {
+			excerpt = append(excerpt, excerptLine{Number: n, Text: scanner.Text(), Current: n == line})
+		}
+	}
+
+	return excerpt
+}
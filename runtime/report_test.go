@@ -0,0 +1,39 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_NewErrorReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := "package main\n\nfunc main() {\n\thandler()\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(source), 0644); err != nil {
+		t.Fatalf("Could not write source: %v", err)
+	}
+
+	output := "# example\n./main.go:4:2: undefined: handler\n./main.go:5:1: missing return\n./other.go:1:1: expected 'package'\ngo: no Go files\n"
+	report := newErrorReport(output, ParseDiagnostics(output), dir, "vscode")
+
+	test.Expect(t, len(report.Other), 1)
+	test.Expect(t, len(report.Files), 2)
+	test.Expect(t, report.Files[0].File, "./main.go")
+	test.Expect(t, len(report.Files[0].Problems), 2)
+
+	problem := report.Files[0].Problems[0]
+	test.Expect(t, string(problem.Link), "vscode://file"+filepath.ToSlash(filepath.Join(dir, "main.go"))+":4:2")
+	test.Expect(t, len(problem.Excerpt), 5)
+	test.Expect(t, problem.Excerpt[0].Number, 1)
+	test.Expect(t, problem.Excerpt[3], excerptLine{Number: 4, Text: "\thandler()", Current: true})
+
+	test.Expect(t, len(report.Files[1].Problems[0].Excerpt), 0)
+}
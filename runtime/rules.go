@@ -0,0 +1,169 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+const (
+	// ActionRebuild rebuilds the binary and restarts the app
+	ActionRebuild = "rebuild"
+	// ActionRestart restarts the app without rebuilding it
+	ActionRestart = "restart"
+	// ActionSignal sends a signal to the running app
+	ActionSignal = "signal"
+	// ActionRefresh only refreshes the browsers connected for live reload
+	ActionRefresh = "refresh"
+)
+
+// actionWeights orders the actions so that a batch of changes is handled by
+// the one that subsumes the others
+var actionWeights = map[string]int{
+	ActionRefresh: 1,
+	ActionSignal:  2,
+	ActionRestart: 3,
+	ActionRebuild: 4,
+}
+
+// signals are the signal names accepted in rules
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// Action is what to do in response to a change
+type Action struct {
+	Kind string
+	// Signal is sent to the app by ActionSignal
+	Signal syscall.Signal
+}
+
+// Rebuild is the action for changes not matched by any rule
+var Rebuild = Action{Kind: ActionRebuild}
+
+// ParseAction parses "rebuild", "restart", "refresh" or "signal:NAME", where
+// NAME is a signal such as HUP or SIGUSR1
+func ParseAction(action string) (Action, error) {
+	kind, name := action, ""
+	if i := strings.Index(action, ":"); i >= 0 {
+		kind, name = action[:i], action[i+1:]
+	}
+
+	switch kind {
+	case ActionRebuild, ActionRestart, ActionRefresh:
+		if name != "" {
+			return Action{}, fmt.Errorf("action %q does not take a signal", kind)
+		}
+		return Action{Kind: kind}, nil
+	case ActionSignal:
+		sig, err := ParseSignal(name)
+		if err != nil {
+			return Action{}, err
+		}
+		return Action{Kind: kind, Signal: sig}, nil
+	default:
+		return Action{}, fmt.Errorf("unknown action %q", action)
+	}
+}
+
+// ParseSignal parses a signal name such as HUP or SIGHUP
+func ParseSignal(name string) (syscall.Signal, error) {
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal %q", name)
+	}
+	return sig, nil
+}
+
+// ParseStopSignal parses the signal an app is asked to exit with: INT, TERM,
+// QUIT or HUP, with or without the SIG prefix
+func ParseStopSignal(name string) (syscall.Signal, error) {
+	sig, err := ParseSignal(name)
+	if err != nil {
+		return 0, err
+	}
+	switch sig {
+	case syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP:
+		return sig, nil
+	}
+	return 0, fmt.Errorf("%s is not a stop signal, use INT, TERM, QUIT or HUP", name)
+}
+
+// Merge returns the action handling the changes of both a and b
+func (a Action) Merge(b Action) Action {
+	if actionWeights[b.Kind] > actionWeights[a.Kind] {
+		return b
+	}
+	return a
+}
+
+func (a Action) String() string {
+	if a.Kind == ActionSignal {
+		for name, sig := range signals {
+			if sig == a.Signal {
+				return a.Kind + ":" + name
+			}
+		}
+	}
+	return a.Kind
+}
+
+// ActionRule maps the files matching a pattern to an action
+type ActionRule struct {
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+}
+
+// ParseActionRule parses a rule written as "pattern=action"
+func ParseActionRule(value string) (ActionRule, error) {
+	i := strings.LastIndex(value, "=")
+	if i <= 0 || i == len(value)-1 {
+		return ActionRule{}, fmt.Errorf("invalid rule %q, expected pattern=action", value)
+	}
+	return ActionRule{Pattern: value[:i], Action: value[i+1:]}, nil
+}
+
+// Rules decides how to respond to changes under a root
+type Rules struct {
+	root    string
+	rules   []rule
+	actions []Action
+}
+
+// NewRules constructs the rules for the tree at root. Patterns use the
+// .gitignore syntax and the first rule matching a file decides its action.
+func NewRules(root string, rules []ActionRule) (*Rules, error) {
+	r := &Rules{root: abs(root)}
+	for _, ar := range rules {
+		action, err := ParseAction(ar.Action)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %v", ar.Pattern, err)
+		}
+		r.rules = append(r.rules, parseRule(ar.Pattern))
+		r.actions = append(r.actions, action)
+	}
+	return r, nil
+}
+
+// Match returns the action of the first rule matching file or a directory
+// containing it, or false if no rule matches
+func (r *Rules) Match(file string) (Action, bool) {
+	rel, ok := within(r.root, abs(file))
+	if !ok || rel == "." {
+		return Action{}, false
+	}
+
+	parts := strings.Split(rel, "/")
+	for i, rule := range r.rules {
+		for j := 1; j <= len(parts); j++ {
+			if rule.match(strings.Join(parts[:j], "/"), j < len(parts)) {
+				return r.actions[i], true
+			}
+		}
+	}
+	return Action{}, false
+}
@@ -0,0 +1,97 @@
+package runtime
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_ParseAction(t *testing.T) {
+	action, err := ParseAction("restart")
+	test.Expect(t, err, nil)
+	test.Expect(t, action, Action{Kind: ActionRestart})
+
+	action, err = ParseAction("signal:SIGHUP")
+	test.Expect(t, err, nil)
+	test.Expect(t, action, Action{Kind: ActionSignal, Signal: syscall.SIGHUP})
+	test.Expect(t, action.String(), "signal:HUP")
+
+	_, err = ParseAction("signal:NOPE")
+	test.Expect(t, err == nil, false)
+
+	_, err = ParseAction("restart:HUP")
+	test.Expect(t, err == nil, false)
+
+	_, err = ParseAction("explode")
+	test.Expect(t, err == nil, false)
+}
+
+func Test_ParseStopSignal(t *testing.T) {
+	sig, err := ParseStopSignal("SIGTERM")
+	test.Expect(t, err, nil)
+	test.Expect(t, sig, syscall.SIGTERM)
+
+	sig, err = ParseStopSignal("quit")
+	test.Expect(t, err, nil)
+	test.Expect(t, sig, syscall.SIGQUIT)
+
+	_, err = ParseStopSignal("KILL")
+	test.Expect(t, err.Error(), "KILL is not a stop signal, use INT, TERM, QUIT or HUP")
+
+	_, err = ParseStopSignal("NOPE")
+	test.Expect(t, err == nil, false)
+}
+
+func Test_ParseActionRule(t *testing.T) {
+	rule, err := ParseActionRule("config/*.yaml=restart")
+	test.Expect(t, err, nil)
+	test.Expect(t, rule, ActionRule{Pattern: "config/*.yaml", Action: "restart"})
+
+	_, err = ParseActionRule("config/*.yaml")
+	test.Expect(t, err == nil, false)
+
+	_, err = ParseActionRule("=restart")
+	test.Expect(t, err == nil, false)
+}
+
+func Test_Action_Merge(t *testing.T) {
+	refresh := Action{Kind: ActionRefresh}
+	restart := Action{Kind: ActionRestart}
+
+	test.Expect(t, refresh.Merge(restart), restart)
+	test.Expect(t, restart.Merge(refresh), restart)
+	test.Expect(t, restart.Merge(Rebuild), Rebuild)
+	test.Expect(t, Action{}.Merge(refresh), refresh)
+}
+
+func Test_Rules_Match(t *testing.T) {
+	root := filepath.Join("testdata", "app")
+	rules, err := NewRules(root, []ActionRule{
+		{Pattern: "config/*.yaml", Action: "restart"},
+		{Pattern: "templates/**", Action: "signal:HUP"},
+		{Pattern: "static/", Action: "refresh"},
+		{Pattern: "*.go", Action: "rebuild"},
+	})
+	test.Expect(t, err, nil)
+
+	match := func(file string) string {
+		action, ok := rules.Match(filepath.Join(root, filepath.FromSlash(file)))
+		if !ok {
+			return ""
+		}
+		return action.String()
+	}
+
+	test.Expect(t, match("config/app.yaml"), "restart")
+	test.Expect(t, match("config/nested/app.yaml"), "")
+	test.Expect(t, match("templates/layouts/main.html"), "signal:HUP")
+	test.Expect(t, match("static/css/app.css"), "refresh")
+	test.Expect(t, match("handlers/home.go"), "rebuild")
+	test.Expect(t, match("README.md"), "")
+	test.Expect(t, match("../outside.go"), "")
+
+	_, err = NewRules(root, []ActionRule{{Pattern: "*.go", Action: "explode"}})
+	test.Expect(t, err == nil, false)
+}
@@ -1,12 +1,19 @@
 package runtime
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -18,39 +25,111 @@ type Runner interface {
 	Info() (os.FileInfo, error)
 	// SetWriter provides an output sink for the runtime
 	SetWriter(io.Writer)
+	// SetListener passes a listening socket to the executable, following
+	// the systemd LISTEN_FDS convention
+	SetListener(file *os.File)
+	// SetStop configures how the executable is stopped: the pre-stop url
+	// requested first, if any, the signal it is then sent, and how long it
+	// is given to exit before it is killed
+	SetStop(preStop *url.URL, sig os.Signal, timeout time.Duration)
+	// Output returns the most recent output of the executable
+	Output() string
+	// ProcessState returns the exit status of the executable once it has
+	// exited on its own, or nil while it is running
+	ProcessState() *os.ProcessState
 	// Kill terminates the executable
 	Kill() error
+	// Signal sends a signal to the executable, if it is running
+	Signal(sig os.Signal) error
+	// Replace starts a new generation of the executable listening on port
+	// next to the running one, and passes it to ready along with a channel
+	// closed when it exits. Once ready returns nil the new generation takes
+	// over and the previous one is stopped gracefully; otherwise the new
+	// generation is stopped and the previous one keeps running.
+	Replace(port int, ready func(command *exec.Cmd, done <-chan struct{}) error) error
 }
 
 type runner struct {
+	mu        sync.Mutex
 	bin       string
 	args      []string
 	writer    io.Writer
+	listener  *os.File
+	preStop   *url.URL
+	stopSig   os.Signal
+	stopAfter time.Duration
 	command   *exec.Cmd
+	done      chan struct{}
+	port      int
 	starttime time.Time
+	state     *BuildState
+	output    *outputBuffer
 }
 
+// outputLines is the number of lines of app output retained by the runner
+const outputLines = 200
+
+// stopTimeout is how long the app is given to exit by default
+const stopTimeout = 3 * time.Second
+
+// preStopTimeout is how long the pre-stop hook is given to respond; the stop
+// timeout only starts once the app is signalled
+const preStopTimeout = 5 * time.Second
+
+// outputDrain is how long the output of an exited app is still read while
+// processes it spawned hold on to its output
+const outputDrain = 500 * time.Millisecond
+
 // NewRunner constructs a new runtime
-func NewRunner(bin string, args ...string) Runner {
+func NewRunner(bin string, state *BuildState, args ...string) Runner {
+	if state == nil {
+		state = NewBuildState()
+	}
+
 	return &runner{
 		bin:       bin,
 		args:      args,
 		writer:    ioutil.Discard,
+		stopSig:   os.Interrupt,
+		stopAfter: stopTimeout,
 		starttime: time.Now(),
+		state:     state,
+		output:    newOutputBuffer(outputLines),
 	}
 }
 
 func (r *runner) Run() (*exec.Cmd, error) {
+	// a running binary keeps serving while the next one is built
+	r.mu.Lock()
+	if r.command != nil && !r.Exited() && !r.needsRefresh() {
+		command := r.command
+		r.mu.Unlock()
+		return command, nil
+	}
+	r.mu.Unlock()
+
+	// never start a binary that is still being written
+	r.state.Wait(0)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	if r.needsRefresh() {
-		r.Kill()
+		r.kill()
+	}
+	if r.Exited() {
+		// the processes the app spawned may have outlived it
+		if err := r.kill(); err != nil {
+			log.Print("Error running: ", err)
+			return r.command, err
+		}
 	}
 
-	if r.command == nil || r.Exited() {
+	if r.command == nil {
 		err := r.runBin()
 		if err != nil {
 			log.Print("Error running: ", err)
 		}
-		time.Sleep(250 * time.Millisecond)
 		return r.command, err
 	} else {
 		return r.command, nil
@@ -66,30 +145,93 @@ func (r *runner) SetWriter(writer io.Writer) {
 	r.writer = writer
 }
 
+func (r *runner) SetListener(file *os.File) {
+	r.listener = file
+}
+
+func (r *runner) SetStop(preStop *url.URL, sig os.Signal, timeout time.Duration) {
+	r.preStop = preStop
+	r.stopSig = sig
+	r.stopAfter = timeout
+}
+
+func (r *runner) Output() string {
+	r.mu.Lock()
+	output := r.output
+	r.mu.Unlock()
+	return output.String()
+}
+
+func (r *runner) ProcessState() *os.ProcessState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.Exited() {
+		return nil
+	}
+	return r.command.ProcessState
+}
+
 func (r *runner) Kill() error {
-	if r.command != nil && r.command.Process != nil {
-		done := make(chan error)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.kill()
+}
+
+func (r *runner) Signal(sig os.Signal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.command == nil || r.Exited() {
+		return nil
+	}
+	return r.command.Process.Signal(sig)
+}
+
+func (r *runner) Replace(port int, ready func(command *exec.Cmd, done <-chan struct{}) error) error {
+	// never start a binary that is still being written
+	r.state.Wait(0)
+
+	command, done, output, err := r.start(port)
+	if err != nil {
+		return err
+	}
+
+	// the current generation serves requests until the new one is ready
+	if err := ready(command, done); err != nil {
+		r.stop(command, done, port)
+		return err
+	}
+
+	// the previous generation keeps its output to itself while it stops
+	r.mu.Lock()
+	previous, previousDone, previousPort := r.command, r.done, r.port
+	r.command, r.done, r.port, r.output = command, done, port, output
+	r.starttime = time.Now()
+	r.mu.Unlock()
+
+	if previous != nil {
 		go func() {
-			r.command.Wait()
-			close(done)
+			if err := r.stop(previous, previousDone, previousPort); err != nil {
+				log.Print("failed to stop the previous app: ", err)
+			}
 		}()
+	}
+	return nil
+}
 
-		// Trying a "soft" kill first
-		if runtime.GOOS == "windows" {
-			if err := r.command.Process.Kill(); err != nil {
-				return err
-			}
-		} else if err := r.command.Process.Signal(os.Interrupt); err != nil {
+func (r *runner) kill() error {
+	if r.Exited() {
+		if err := r.stopOrphans(r.command, r.done); err != nil {
 			return err
 		}
+		r.command = nil
+		return nil
+	}
 
-		// Wait for our process to die before we return or hard kill after 3 sec
-		select {
-		case <-time.After(3 * time.Second):
-			if err := r.command.Process.Kill(); err != nil {
-				log.Println("failed to kill: ", err)
-			}
-		case <-done:
+	if r.command != nil {
+		if err := r.stop(r.command, r.done, r.port); err != nil {
+			return err
 		}
 		r.command = nil
 	}
@@ -97,35 +239,195 @@ func (r *runner) Kill() error {
 	return nil
 }
 
-func (r *runner) Exited() bool {
-	return r.command != nil && r.command.ProcessState != nil && r.command.ProcessState.Exited()
+// stop asks the process listening on port, and the processes it spawned, to
+// exit, calling the pre-stop hook first, and kills them if they are still
+// running once the stop timeout elapses
+func (r *runner) stop(command *exec.Cmd, done <-chan struct{}, port int) error {
+	process := command.Process
+	if process == nil {
+		return nil
+	}
+
+	if r.preStop != nil {
+		if err := callPreStop(r.preStop, port, preStopTimeout); err != nil {
+			log.Print("pre-stop hook failed: ", err)
+		}
+	}
+
+	// Trying a "soft" kill first
+	if runtime.GOOS == "windows" {
+		if err := process.Kill(); err != nil {
+			return err
+		}
+	} else if err := signalGroup(process, r.stopSig); err != nil && !groupExited(process) {
+		return err
+	}
+	started := time.Now()
+	deadline := time.After(r.stopAfter)
+
+	// Wait for our processes to die before we return or hard kill on timeout
+	if waitGroup(process, done, deadline) {
+		elapsed := time.Since(started).Round(time.Millisecond)
+		if runtime.GOOS == "windows" {
+			// there is no signal to stop gracefully with
+			log.Printf("app killed in %v", elapsed)
+		} else {
+			log.Printf("app stopped gracefully in %v", elapsed)
+		}
+		return nil
+	}
+	if err := signalGroup(process, os.Kill); err != nil {
+		log.Println("failed to kill: ", err)
+	}
+	if !waitGroup(process, done, time.After(time.Second)) {
+		return errors.New("processes started by the app are still running")
+	}
+	log.Printf("app killed after not stopping within %v", r.stopAfter)
+	return nil
 }
 
-func (r *runner) runBin() error {
-	r.command = exec.Command(r.bin, r.args...)
-	stdout, err := r.command.StdoutPipe()
-	if err != nil {
+// stopOrphans stops the processes spawned by an app that has exited, such as
+// by a wrapper script, which would otherwise keep its port bound
+func (r *runner) stopOrphans(command *exec.Cmd, done <-chan struct{}) error {
+	process := command.Process
+	if process == nil || groupExited(process) {
+		return nil
+	}
+
+	if err := signalGroup(process, r.stopSig); err != nil && !groupExited(process) {
 		return err
 	}
-	stderr, err := r.command.StderrPipe()
+	if waitGroup(process, done, time.After(r.stopAfter)) {
+		log.Print("stopped the processes left running by the app")
+		return nil
+	}
+	if err := signalGroup(process, os.Kill); err != nil {
+		log.Println("failed to kill: ", err)
+	}
+	if !waitGroup(process, done, time.After(time.Second)) {
+		return errors.New("processes started by the app are still running")
+	}
+	log.Print("killed the processes left running by the app")
+	return nil
+}
+
+// callPreStop requests the pre-stop hook of the app, on port unless it is
+// zero
+func callPreStop(hook *url.URL, port int, timeout time.Duration) error {
+	to := *hook
+	if port != 0 {
+		to.Host = net.JoinHostPort(hook.Hostname(), strconv.Itoa(port))
+	}
+
+	client := &http.Client{Transport: newBackendTransport(false), Timeout: timeout}
+	res, err := client.Get(to.String())
 	if err != nil {
 		return err
 	}
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s returned %s", to.Path, res.Status)
+	}
+	return nil
+}
+
+// waitGroup waits for the process to exit, followed by the processes it
+// spawned, which may hold on to its port; it reports false on timeout
+func waitGroup(process *os.Process, done <-chan struct{}, timeout <-chan time.Time) bool {
+	select {
+	case <-done:
+	case <-timeout:
+		return false
+	}
+
+	for !groupExited(process) {
+		select {
+		case <-timeout:
+			return false
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return true
+}
 
-	if err = r.command.Start(); err != nil {
+func (r *runner) Exited() bool {
+	if r.command == nil || r.done == nil {
+		return false
+	}
+
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *runner) runBin() error {
+	r.command = nil
+	command, done, output, err := r.start(r.port)
+	if err != nil {
 		return err
 	}
 
 	r.starttime = time.Now()
-
-	go io.Copy(r.writer, stdout)
-	go io.Copy(r.writer, stderr)
-	go r.command.Wait()
+	r.command = command
+	r.done = done
+	r.output = output
 
 	return nil
 }
 
+// start starts the binary, listening on port unless it is zero, and returns
+// a channel closed once the process has exited along with its output
+func (r *runner) start(port int) (*exec.Cmd, chan struct{}, *outputBuffer, error) {
+	// exec copies both streams and Wait returns once they are drained, so
+	// the tail of a crashing process's output is never lost; WaitDelay stops
+	// helpers that inherited the streams from delaying the exit past that
+	output := newOutputBuffer(outputLines)
+	writer := io.MultiWriter(r.writer, output)
+	command := exec.Command(r.bin, r.args...)
+	var env []string
+	if port != 0 {
+		env = append(env, "PORT="+strconv.Itoa(port))
+	}
+	if r.listener != nil {
+		// LISTEN_PID names the process the socket is meant for, which only
+		// the shell knows before it execs the binary in its place
+		args := append([]string{"-c", `export LISTEN_PID=$$; exec "$0" "$@"`, r.bin}, r.args...)
+		command = exec.Command("/bin/sh", args...)
+		command.ExtraFiles = []*os.File{r.listener}
+		env = append(env, "LISTEN_FDS=1", "LISTEN_FDNAMES=http")
+	}
+	if len(env) > 0 {
+		command.Env = append(os.Environ(), env...)
+	}
+	command.Stdout = writer
+	command.Stderr = writer
+	command.WaitDelay = outputDrain
+	setProcessGroup(command)
+
+	if err := command.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		command.Wait()
+		close(done)
+	}()
+
+	return command, done, output, nil
+}
+
+// needsRefresh reports whether the binary was rebuilt since it was started;
+// binaries started through Replace are only ever replaced the same way
 func (r *runner) needsRefresh() bool {
+	if r.port != 0 {
+		return false
+	}
+
 	info, err := r.Info()
 	if err != nil {
 		return false
@@ -1,9 +1,14 @@
 package runtime
 
 import (
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -12,23 +17,34 @@ import (
 
 func Test_NewRunner(t *testing.T) {
 	filename := getBinFile()
-	runner := NewRunner(filename)
+	runner := NewRunner(filename, NewBuildState())
 
 	fi, _ := runner.Info()
 	test.Expect(t, fi.Name(), filepath.Base(filename))
 }
 
 func Test_Runner_Run(t *testing.T) {
-	runner := NewRunner(getBinFile())
+	runner := NewRunner(getBinFile(), NewBuildState())
 
 	cmd, err := runner.Run()
 	test.Expect(t, err, nil)
 	test.Expect(t, cmd.Process == nil, false)
 }
 
+func Test_Runner_Output(t *testing.T) {
+	runner := NewRunner(getBinFile(), NewBuildState())
+
+	_, err := runner.Run()
+	test.Expect(t, err, nil)
+	defer runner.Kill()
+
+	time.Sleep(500 * time.Millisecond)
+	test.Expect(t, strings.TrimSpace(runner.Output()), "Hello world")
+}
+
 func Test_Runner_Kill(t *testing.T) {
 	bin := getBinFile()
-	runner := NewRunner(bin)
+	runner := NewRunner(bin, NewBuildState())
 
 	cmd1, err := runner.Run()
 	test.Expect(t, err, nil)
@@ -58,3 +74,67 @@ func getBinFile() string {
 	}
 	return bin
 }
+
+func Test_Runner_Replace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no serving script on windows")
+	}
+	runner := NewRunner(filepath.Join("testdata", "serving"), NewBuildState())
+	defer runner.Kill()
+
+	first, err := runner.Run()
+	test.Expect(t, err, nil)
+
+	// a generation that is not ready is stopped, keeping the running one
+	var rejected *exec.Cmd
+	err = runner.Replace(3002, func(command *exec.Cmd, done <-chan struct{}) error {
+		rejected = command
+		return errors.New("not ready")
+	})
+	test.Expect(t, err.Error(), "not ready")
+	test.Expect(t, rejected.ProcessState != nil, true)
+	current, _ := runner.Run()
+	test.Expect(t, current, first)
+
+	// a ready generation takes over on its own port
+	var replacement *exec.Cmd
+	err = runner.Replace(3003, func(command *exec.Cmd, done <-chan struct{}) error {
+		replacement = command
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	test.Expect(t, err, nil)
+	current, _ = runner.Run()
+	test.Expect(t, current, replacement)
+	test.Expect(t, strings.TrimSpace(runner.Output()), "listening on 3003")
+
+	// and the previous generation is stopped gracefully
+	deadline := time.Now().Add(3 * time.Second)
+	for first.Process.Signal(syscall.Signal(0)) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	test.Expect(t, first.Process.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
+
+func Test_Runner_SetListener(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("socket activation is not supported on windows")
+	}
+	socket, err := ListenSocket("http://127.0.0.1:5694")
+	test.Expect(t, err, nil)
+	defer socket.Close()
+
+	runner := NewRunner(filepath.Join("testdata", "socket_activated"), NewBuildState())
+	runner.SetListener(socket)
+
+	cmd, err := runner.Run()
+	test.Expect(t, err, nil)
+	defer runner.Kill()
+
+	// the pid seen by the app is the one the socket is meant for
+	for runner.ProcessState() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	pid := strconv.Itoa(cmd.Process.Pid)
+	test.Expect(t, strings.TrimSpace(runner.Output()), "1 "+pid+" "+pid+"\nfd 3 open")
+}
@@ -0,0 +1,127 @@
+//go:build !windows
+// +build !windows
+
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_Runner_Kill_Process_Group(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc to tell exited processes from zombies")
+	}
+	runner := NewRunner(filepath.Join("testdata", "spawning"), NewBuildState())
+	runner.SetStop(nil, os.Interrupt, 200*time.Millisecond)
+
+	_, err := runner.Run()
+	test.Expect(t, err, nil)
+
+	var helper int
+	for helper == 0 {
+		time.Sleep(10 * time.Millisecond)
+		fmt.Sscanf(runner.Output(), "helper %d", &helper)
+	}
+
+	test.Expect(t, runner.Kill(), nil)
+	test.Expect(t, alive(helper), false)
+}
+
+// alive reports whether the process is running, not counting orphans that
+// have exited but were not reaped
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	return err == nil && !strings.Contains(string(stat), ") Z ")
+}
+
+func Test_Runner_Stop(t *testing.T) {
+	hooked := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hooked <- r.URL.Path
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer ts.Close()
+	hook, _ := url.Parse(ts.URL + "/shutdown")
+
+	runner := NewRunner(filepath.Join("testdata", "draining"), NewBuildState())
+	runner.SetStop(hook, syscall.SIGTERM, 200*time.Millisecond)
+
+	cmd, err := runner.Run()
+	test.Expect(t, err, nil)
+	for runner.Output() == "" {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the hook is called before the app is asked to exit with the signal,
+	// and a slow hook leaves the app its time to stop
+	test.Expect(t, runner.Kill(), nil)
+	test.Expect(t, <-hooked, "/shutdown")
+	test.Expect(t, strings.TrimSpace(runner.Output()), "started\nstopping on TERM")
+	test.Expect(t, cmd.ProcessState.Success(), true)
+}
+
+func Test_Runner_Stop_Timeout(t *testing.T) {
+	runner := NewRunner(filepath.Join("testdata", "stubborn"), NewBuildState())
+	runner.SetStop(nil, syscall.SIGTERM, 100*time.Millisecond)
+
+	_, err := runner.Run()
+	test.Expect(t, err, nil)
+	for runner.Output() == "" {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	started := time.Now()
+	test.Expect(t, runner.Kill(), nil)
+	test.Expect(t, time.Since(started) < time.Second, true)
+}
+
+func Test_Runner_Exit_With_Orphans(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc to tell exited processes from zombies")
+	}
+	runner := NewRunner(filepath.Join("testdata", "orphaning"), NewBuildState())
+	runner.SetStop(nil, syscall.SIGTERM, 200*time.Millisecond)
+
+	cmd, err := runner.Run()
+	test.Expect(t, err, nil)
+
+	// the exit is noticed even though the helper still holds the output
+	deadline := time.Now().Add(2 * time.Second)
+	for runner.ProcessState() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	test.Refute(t, runner.ProcessState(), nil)
+	test.Expect(t, strings.TrimSpace(runner.Output()), "crashing")
+	test.Expect(t, groupExited(cmd.Process), false)
+
+	// the helper would keep the port bound, so it is stopped before the app
+	// starts again
+	next, err := runner.Run()
+	test.Expect(t, err, nil)
+	test.Refute(t, next, cmd)
+	test.Expect(t, groupExited(cmd.Process), true)
+
+	// and when the app is killed after exiting
+	deadline = time.Now().Add(2 * time.Second)
+	for runner.ProcessState() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	test.Refute(t, runner.ProcessState(), nil)
+	test.Expect(t, runner.Kill(), nil)
+	test.Expect(t, groupExited(next.Process), true)
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package runtime
+
+import "syscall"
+
+func init() {
+	signals["USR1"] = syscall.SIGUSR1
+	signals["USR2"] = syscall.SIGUSR2
+}
@@ -0,0 +1,26 @@
+package runtime
+
+import (
+	"net"
+	"net/url"
+	"os"
+)
+
+// ListenSocket opens the socket handed to the app with socket activation, on
+// the address requests are proxied to. Connections queue in the kernel while
+// the app restarts, as the socket stays open for as long as reload runs.
+func ListenSocket(proxyTo string) (*os.File, error) {
+	to, err := url.Parse(proxyTo)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", to.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	// the file is a duplicate that keeps the socket listening
+	return listener.(*net.TCPListener).File()
+}
@@ -0,0 +1,22 @@
+package runtime
+
+import (
+	"net"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_ListenSocket(t *testing.T) {
+	socket, err := ListenSocket("http://127.0.0.1:5693")
+	test.Expect(t, err, nil)
+	defer socket.Close()
+
+	// connections queue on the socket until the app accepts them
+	conn, err := net.Dial("tcp", "127.0.0.1:5693")
+	test.Expect(t, err, nil)
+	conn.Close()
+
+	_, err = ListenSocket("http://127.0.0.1:5693")
+	test.Refute(t, err, nil)
+}
@@ -0,0 +1,81 @@
+package runtime
+
+import (
+	"sync"
+	"time"
+)
+
+// BuildState tracks whether a build is in flight so that the builder, runner
+// and proxy agree on when the binary is safe to run
+type BuildState struct {
+	mu       sync.Mutex
+	building bool
+	done     chan struct{}
+	err      error
+}
+
+// NewBuildState constructs a new BuildState with no build in flight
+func NewBuildState() *BuildState {
+	done := make(chan struct{})
+	close(done)
+	return &BuildState{done: done}
+}
+
+// Begin marks a build as in flight; it is a no-op if one already is
+func (s *BuildState) Begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.building {
+		return
+	}
+	s.building = true
+	s.done = make(chan struct{})
+}
+
+// End marks the in-flight build as complete and records its result
+func (s *BuildState) End(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+	if s.building {
+		s.building = false
+		close(s.done)
+	}
+}
+
+// Building reports whether a build is in flight
+func (s *BuildState) Building() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.building
+}
+
+// Err returns the result of the most recently completed build
+func (s *BuildState) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Wait blocks until the in-flight build, if any, completes or the timeout
+// elapses, and reports whether the build completed. A timeout of zero waits
+// indefinitely.
+func (s *BuildState) Wait(timeout time.Duration) bool {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+
+	if timeout <= 0 {
+		<-done
+		return true
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
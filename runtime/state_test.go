@@ -0,0 +1,35 @@
+package runtime
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_BuildState_Idle(t *testing.T) {
+	state := NewBuildState()
+
+	test.Expect(t, state.Building(), false)
+	test.Expect(t, state.Wait(time.Millisecond), true)
+}
+
+func Test_BuildState_Wait(t *testing.T) {
+	state := NewBuildState()
+	state.Begin()
+	state.Begin()
+
+	test.Expect(t, state.Building(), true)
+	test.Expect(t, state.Wait(10*time.Millisecond), false)
+
+	failure := errors.New("build failed")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		state.End(failure)
+	}()
+
+	test.Expect(t, state.Wait(0), true)
+	test.Expect(t, state.Building(), false)
+	test.Expect(t, state.Err(), failure)
+}
@@ -0,0 +1,8 @@
+@echo off
+echo starting up
+echo panic: boom 1>&2
+echo. 1>&2
+echo goroutine 1 [running]: 1>&2
+echo main.main() 1>&2
+echo 	/src/app/main.go:12 +0x25 1>&2
+exit /b 2
@@ -0,0 +1,197 @@
+package runtime
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	caFile      = "ca.pem"
+	caKeyFile   = "ca-key.pem"
+	leafFile    = "localhost.pem"
+	leafKeyFile = "localhost-key.pem"
+
+	caValidity = 10 * 365 * 24 * time.Hour
+	// leafValidity stays below the 825 days browsers accept
+	leafValidity = 365 * 24 * time.Hour
+	// leafRenewal is how long before it expires a leaf certificate is renewed
+	leafRenewal = 30 * 24 * time.Hour
+)
+
+// LocalCertificate describes the files of a certificate for local development
+type LocalCertificate struct {
+	// CAFile is the certificate of the local authority, to be trusted once
+	CAFile string
+	// CertFile and KeyFile are the certificate served by the proxy and its key
+	CertFile string
+	KeyFile  string
+}
+
+// CertificateDir returns the directory caching the local certificates
+func CertificateDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "reload", "certs"), nil
+}
+
+// EnsureLocalCertificate returns a certificate for the hosts signed by a
+// local certificate authority, both cached in dir. The authority is created
+// once, and the certificate is reissued when it is about to expire or does
+// not cover every host.
+func EnsureLocalCertificate(dir string, hosts []string) (*LocalCertificate, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	local := &LocalCertificate{
+		CAFile:   filepath.Join(dir, caFile),
+		CertFile: filepath.Join(dir, leafFile),
+		KeyFile:  filepath.Join(dir, leafKeyFile),
+	}
+
+	ca, caKey, err := loadPair(local.CAFile, filepath.Join(dir, caKeyFile))
+	if err != nil {
+		if ca, caKey, err = createCA(local.CAFile, filepath.Join(dir, caKeyFile)); err != nil {
+			return nil, fmt.Errorf("unable to create certificate authority: %v", err)
+		}
+	}
+
+	if leaf, _, err := loadPair(local.CertFile, local.KeyFile); err == nil && valid(leaf, ca, hosts) {
+		return local, nil
+	}
+	if err := createLeaf(local.CertFile, local.KeyFile, ca, caKey, hosts); err != nil {
+		return nil, fmt.Errorf("unable to create certificate: %v", err)
+	}
+	return local, nil
+}
+
+// valid reports whether the leaf certificate was issued by ca, covers every
+// host and is not about to expire
+func valid(leaf *x509.Certificate, ca *x509.Certificate, hosts []string) bool {
+	if leaf.CheckSignatureFrom(ca) != nil || time.Now().Add(leafRenewal).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func createCA(certFile string, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	template, err := newTemplate(caValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	host, _ := os.Hostname()
+	template.Subject = pkix.Name{Organization: []string{"reload development CA"}, CommonName: "reload " + host}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	return createPair(certFile, keyFile, template, nil, nil)
+}
+
+func createLeaf(certFile string, keyFile string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string) error {
+	template, err := newTemplate(leafValidity)
+	if err != nil {
+		return err
+	}
+	template.Subject = pkix.Name{Organization: []string{"reload development certificate"}}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	_, _, err = createPair(certFile, keyFile, template, ca, caKey)
+	return err
+}
+
+func newTemplate(validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+// createPair creates a key and a certificate signed by parent, or
+// self-signed without one, and writes both as PEM files
+func createPair(certFile string, keyFile string, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := writePEM(keyFile, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+func loadPair(certFile string, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, errors.New("unexpected key type")
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	return cert, key, err
+}
+
+func writePEM(file string, kind string, der []byte, perm os.FileMode) error {
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &pem.Block{Type: kind, Bytes: der}); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf.Bytes(), perm)
+}
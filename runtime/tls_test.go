@@ -0,0 +1,54 @@
+package runtime
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_EnsureLocalCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	local, err := EnsureLocalCertificate(dir, hosts)
+	test.Expect(t, err, nil)
+	test.Expect(t, local.CAFile, filepath.Join(dir, "ca.pem"))
+
+	ca, _, err := loadPair(local.CAFile, filepath.Join(dir, "ca-key.pem"))
+	test.Expect(t, err, nil)
+	leaf, _, err := loadPair(local.CertFile, local.KeyFile)
+	test.Expect(t, err, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, host := range hosts {
+		_, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		test.Expect(t, err, nil)
+	}
+
+	info, err := os.Stat(local.KeyFile)
+	test.Expect(t, err, nil)
+	test.Expect(t, info.Mode().Perm(), os.FileMode(0600))
+
+	// the certificate is reused as long as it covers the hosts
+	_, err = EnsureLocalCertificate(dir, hosts[:1])
+	test.Expect(t, err, nil)
+	reused, _, _ := loadPair(local.CertFile, local.KeyFile)
+	test.Expect(t, reused.SerialNumber.Cmp(leaf.SerialNumber), 0)
+
+	// and reissued by the same authority for new hosts
+	_, err = EnsureLocalCertificate(dir, append(hosts, "app.test"))
+	test.Expect(t, err, nil)
+	reissued, _, _ := loadPair(local.CertFile, local.KeyFile)
+	test.Expect(t, reissued.SerialNumber.Cmp(leaf.SerialNumber) == 0, false)
+	test.Expect(t, reissued.VerifyHostname("app.test"), nil)
+	test.Expect(t, reissued.CheckSignatureFrom(ca), nil)
+}
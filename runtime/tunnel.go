@@ -0,0 +1,173 @@
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// closeFrame is the websocket close frame sent to clients when the app
+// restarts: status 1012 (service restart) and a reason
+var closeFrame = append([]byte{0x88, 12, 0x03, 0xf4}, "restarting"...)
+
+// longLived reports whether a request usually stays open for as long as the
+// app runs, such as a websocket, an event stream or a streaming gRPC call
+func longLived(req *http.Request) bool {
+	return req.Header.Get("Upgrade") != "" ||
+		strings.Contains(req.Header.Get("Accept"), "text/event-stream") ||
+		grpc(req)
+}
+
+// tunnel is a long-lived connection to the app through the proxy
+type tunnel struct {
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	websocket bool
+	conn      net.Conn
+	closing   bool
+}
+
+// close ends the connection, sending a close frame to websocket clients
+func (t *tunnel) close() {
+	t.mu.Lock()
+	if !t.closing && t.conn != nil {
+		if t.websocket {
+			t.conn.Write(closeFrame)
+		}
+		t.conn.Close()
+	}
+	t.closing = true
+	t.mu.Unlock()
+
+	// ends streamed responses and the connection to the app
+	t.cancel()
+}
+
+// tunnels tracks the open tunnels of each generation of the app, a
+// generation being a process started by the runner
+type tunnels struct {
+	mu         sync.Mutex
+	command    *exec.Cmd
+	generation int
+	open       map[*tunnel]int
+}
+
+func newTunnels() *tunnels {
+	return &tunnels{open: make(map[*tunnel]int)}
+}
+
+// add tracks a tunnel to the process started by command
+func (ts *tunnels) add(t *tunnel, command *exec.Cmd) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.start(command)
+	ts.open[t] = ts.generation
+}
+
+func (ts *tunnels) remove(t *tunnel) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.open, t)
+}
+
+// started begins the generation of the process started by command, unless
+// it is the current one, returning the generation before it
+func (ts *tunnels) started(command *exec.Cmd) int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.start(command)
+	return ts.generation - 1
+}
+
+func (ts *tunnels) start(command *exec.Cmd) {
+	if command != ts.command {
+		ts.command = command
+		ts.generation++
+	}
+}
+
+// current returns the generation of the latest process
+func (ts *tunnels) current() int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.generation
+}
+
+// close closes the tunnels of the generation and any before it, returning
+// how many were closed
+func (ts *tunnels) close(generation int) int {
+	ts.mu.Lock()
+	var closing []*tunnel
+	for t, g := range ts.open {
+		if g <= generation {
+			closing = append(closing, t)
+			delete(ts.open, t)
+		}
+	}
+	ts.mu.Unlock()
+
+	for _, t := range closing {
+		t.close()
+	}
+	return len(closing)
+}
+
+// tunnelWriter records the client connection of a tunnel when the reverse
+// proxy takes it over for an upgrade
+type tunnelWriter struct {
+	http.ResponseWriter
+	tunnel *tunnel
+}
+
+func (w *tunnelWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *tunnelWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection cannot be upgraded")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w.tunnel.mu.Lock()
+	defer w.tunnel.mu.Unlock()
+	if w.tunnel.closing {
+		conn.Close()
+		return nil, nil, errors.New("app is restarting")
+	}
+
+	wrapped := &tunnelConn{Conn: conn, tunnel: w.tunnel}
+	w.tunnel.conn = conn
+	return wrapped, bufio.NewReadWriter(rw.Reader, bufio.NewWriter(wrapped)), nil
+}
+
+func (w *tunnelWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// tunnelConn keeps writes from the app from interleaving with a close frame
+type tunnelConn struct {
+	net.Conn
+	tunnel *tunnel
+}
+
+func (c *tunnelConn) Write(p []byte) (int, error) {
+	c.tunnel.mu.Lock()
+	defer c.tunnel.mu.Unlock()
+	if c.tunnel.closing {
+		return 0, io.ErrClosedPipe
+	}
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,123 @@
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/n3integration/reload/test"
+)
+
+func Test_Tunnels_Generations(t *testing.T) {
+	ts := newTunnels()
+	cancelled := 0
+	open := func(command *exec.Cmd) *tunnel {
+		t := &tunnel{cancel: func() { cancelled++ }}
+		ts.add(t, command)
+		return t
+	}
+
+	// a generation lasts as long as the process, however often it is used
+	first, second := &exec.Cmd{}, &exec.Cmd{}
+	open(first)
+	open(first)
+	current := open(second)
+
+	test.Expect(t, ts.close(ts.started(second)), 2)
+	test.Expect(t, cancelled, 2)
+	test.Expect(t, len(ts.open), 1)
+
+	ts.remove(current)
+	test.Expect(t, ts.close(ts.current()), 0)
+}
+
+func Test_Disconnect_Websocket(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	// an app that upgrades and then keeps the connection open
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		io.Copy(ioutil.Discard, rw)
+	}))
+	defer ts.Close()
+
+	err := proxy.Run(&Config{Port: 5690, ProxyTo: ts.URL})
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:5690")
+	test.Expect(t, err, nil)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprint(conn, "GET /ws HTTP/1.1\r\nHost: localhost:5690\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	test.Expect(t, err, nil)
+	test.Expect(t, res.StatusCode, http.StatusSwitchingProtocols)
+
+	proxy.Disconnect()
+
+	// the client is told the service restarts, then the connection ends
+	frame, err := ioutil.ReadAll(reader)
+	test.Expect(t, err, nil)
+	test.Expect(t, string(frame), string(closeFrame))
+}
+
+func Test_Disconnect_Event_Stream(t *testing.T) {
+	builder := newMockBuilder()
+	runner := test.NewMockRunner()
+	proxy := NewProxy(builder, runner, NewBuildState())
+
+	// an app streaming events for as long as the request lasts
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	err := proxy.Run(&Config{Port: 5691, ProxyTo: ts.URL})
+	defer proxy.Close()
+	test.Expect(t, err, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://localhost:5691/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	test.Expect(t, err, nil)
+	defer res.Body.Close()
+
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	test.Expect(t, err, nil)
+	test.Expect(t, line, "data: first\n")
+
+	proxy.Disconnect()
+
+	// the stream ends, so that the browser reconnects
+	rest, _ := ioutil.ReadAll(reader)
+	test.Expect(t, string(rest), "\n")
+	test.Expect(t, ctx.Err(), nil)
+}
@@ -0,0 +1,188 @@
+package runtime
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ConfigError lists every problem found in a configuration
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the configuration as a whole, returning a *ConfigError
+// describing every problem found, or nil
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !validPort(c.Port) {
+		problem("port %d is not a valid port number, use 1-65535", c.Port)
+	}
+	if c.AppPort != 0 && !validPort(c.AppPort) {
+		problem("app_port %d is not a valid port number, use 1-65535", c.AppPort)
+	}
+
+	to, err := url.Parse(c.ProxyTo)
+	if err != nil || to.Host == "" || (to.Scheme != "http" && to.Scheme != "https") {
+		problem("proxy_to %q is not an http or https url", c.ProxyTo)
+		to = nil
+	} else if local(to.Hostname(), c.Laddr) && to.Port() == strconv.Itoa(c.Port) {
+		problem("the proxy would forward to itself on port %d, use a different app_port", c.Port)
+	}
+
+	if c.BlueGreen {
+		switch {
+		case runtime.GOOS == "windows":
+			problem("blue_green is not supported on windows, where a running binary cannot be rebuilt")
+		case !validPort(c.AltPort):
+			problem("alt_port %d is not a valid port number, use 1-65535", c.AltPort)
+		case c.AltPort == c.AppPort || c.AltPort == c.Port:
+			problem("alt_port %d must differ from port and app_port", c.AltPort)
+		}
+		if to != nil && to.Port() != strconv.Itoa(c.AppPort) {
+			problem("blue_green alternates the app between app_port and alt_port, so proxy_to must use app_port %d", c.AppPort)
+		}
+		if c.ReadyTimeout <= 0 {
+			problem("blue_green needs a positive ready_timeout to know when the new app is ready")
+		}
+	}
+
+	if c.SocketActivation {
+		switch {
+		case runtime.GOOS == "windows":
+			problem("socket_activation is not supported on windows")
+		case c.BlueGreen:
+			problem("socket_activation and blue_green cannot be combined, connections already queue on the socket during restarts")
+		}
+		if to != nil && !local(to.Hostname(), "") {
+			problem("socket_activation needs proxy_to to be a local address, not %s", to.Hostname())
+		}
+	}
+
+	switch {
+	case c.TLS && (c.CertFile != "" || c.KeyFile != ""):
+		problem("tls generates a certificate, remove it or cert_file and key_file")
+	case c.CertFile != "" && c.KeyFile == "":
+		problem("cert_file is set without key_file, set both to serve TLS")
+	case c.KeyFile != "" && c.CertFile == "":
+		problem("key_file is set without cert_file, set both to serve TLS")
+	}
+	for _, file := range []struct{ name, path string }{{"cert_file", c.CertFile}, {"key_file", c.KeyFile}} {
+		if file.path != "" && !isFile(file.path) {
+			problem("%s %s does not exist", file.name, file.path)
+		}
+	}
+
+	if c.Path == "" || !isDir(c.Path) {
+		problem("path %q is not a directory", c.Path)
+	}
+	if c.Build != "" && !isDir(c.Build) {
+		problem("build %q is not a directory", c.Build)
+	}
+	if c.Bin == "" {
+		problem("bin is empty, name the binary to build")
+	}
+
+	switch c.Watcher {
+	case WatchAuto, WatchNotify, WatchPoll, "":
+	default:
+		problem("watcher %q is unknown, use %s, %s or %s", c.Watcher, WatchAuto, WatchNotify, WatchPoll)
+	}
+	if c.Watcher == WatchPoll && c.PollInterval <= 0 {
+		problem("poll_interval must be positive when polling for changes")
+	}
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{{"build_wait", c.BuildWait}, {"ready_timeout", c.ReadyTimeout}, {"poll_interval", c.PollInterval}, {"debounce", c.Debounce}, {"stop_timeout", c.StopTimeout}} {
+		if d.value < 0 {
+			problem("%s must not be negative", d.name)
+		}
+	}
+
+	if c.HealthPath != "" && !strings.HasPrefix(c.HealthPath, "/") {
+		problem("health_path %q must start with /", c.HealthPath)
+	}
+	if c.StopSignal != "" {
+		if _, err := ParseStopSignal(c.StopSignal); err != nil {
+			problem("stop_signal: %v", err)
+		}
+	}
+	if c.PreStop != "" && !strings.HasPrefix(c.PreStop, "/") {
+		problem("pre_stop %q must start with /", c.PreStop)
+	}
+	if _, ok := editors[c.Editor]; c.Editor != "" && !ok && !strings.Contains(c.Editor, "{file}") {
+		problem("editor %q is neither vscode, idea, sublime nor atom, nor a url template using {file}", c.Editor)
+	}
+
+	for _, list := range []struct {
+		name     string
+		patterns []string
+	}{{"include", c.Include}, {"exclude", c.Exclude}} {
+		for _, pattern := range list.patterns {
+			if !validPattern(pattern) {
+				problem("%s pattern %q is malformed", list.name, pattern)
+			}
+		}
+	}
+	for _, rule := range c.Rules {
+		if rule.Pattern == "" || !validPattern(rule.Pattern) {
+			problem("rule pattern %q is malformed", rule.Pattern)
+		}
+		if _, err := ParseAction(rule.Action); err != nil {
+			problem("rule %s: %v", rule.Pattern, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// local reports whether host refers to the address the proxy listens on
+func local(host string, laddr string) bool {
+	switch host {
+	case "localhost", "0.0.0.0", "::", laddr:
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validPattern reports whether every segment of a glob pattern is well formed
+func validPattern(pattern string) bool {
+	for _, segment := range strings.Split(strings.TrimPrefix(pattern, "!"), "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func isFile(file string) bool {
+	info, err := os.Stat(file)
+	return err == nil && !info.IsDir()
+}
+
+func isDir(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,114 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n3integration/reload/test"
+)
+
+func validConfig() *Config {
+	return &Config{
+		Port:         3000,
+		AppPort:      3001,
+		ProxyTo:      "http://localhost:3001",
+		Path:         ".",
+		Build:        ".",
+		Bin:          "reload-bin",
+		Watcher:      WatchAuto,
+		PollInterval: Duration(500 * time.Millisecond),
+		Rules:        []ActionRule{{Pattern: "static/**", Action: "refresh"}},
+	}
+}
+
+func Test_Config_Validate(t *testing.T) {
+	test.Expect(t, validConfig().Validate(), nil)
+}
+
+func Test_Config_Validate_ReportsEveryProblem(t *testing.T) {
+	config := validConfig()
+	config.ProxyTo = "http://127.0.0.1:3000"
+	config.CertFile = "testdata/missing.crt"
+	config.Path = "im/not/here"
+	config.Watcher = "inotify"
+	config.Include = []string{"[a-"}
+	config.Rules = append(config.Rules, ActionRule{Pattern: "config/*.yaml", Action: "reboot"})
+
+	err := config.Validate()
+	test.Refute(t, err, nil)
+
+	problems := err.(*ConfigError).Problems
+	test.Expect(t, len(problems), 7)
+	test.Expect(t, problems[0], "the proxy would forward to itself on port 3000, use a different app_port")
+	test.Expect(t, problems[1], "cert_file is set without key_file, set both to serve TLS")
+	test.Expect(t, problems[2], "cert_file testdata/missing.crt does not exist")
+	test.Expect(t, problems[3], `path "im/not/here" is not a directory`)
+	test.Expect(t, problems[4], `watcher "inotify" is unknown, use auto, notify or poll`)
+	test.Expect(t, problems[5], `include pattern "[a-" is malformed`)
+	test.Expect(t, problems[6], `rule config/*.yaml: unknown action "reboot"`)
+	test.Expect(t, strings.HasPrefix(err.Error(), "invalid configuration:\n  - the proxy"), true)
+}
+
+func Test_Config_Validate_Ports(t *testing.T) {
+	config := validConfig()
+	config.Port = 70000
+	config.ProxyTo = "localhost:3001"
+
+	problems := config.Validate().(*ConfigError).Problems
+	test.Expect(t, len(problems), 2)
+	test.Expect(t, problems[0], "port 70000 is not a valid port number, use 1-65535")
+	test.Expect(t, problems[1], `proxy_to "localhost:3001" is not an http or https url`)
+}
+
+func Test_Config_Validate_BlueGreen(t *testing.T) {
+	config := validConfig()
+	config.BlueGreen = true
+	config.AltPort = 3002
+	config.ReadyTimeout = Duration(30 * time.Second)
+	test.Expect(t, config.Validate(), nil)
+
+	config.AltPort = 3001
+	config.ProxyTo = "http://localhost:8080"
+	config.ReadyTimeout = 0
+
+	problems := config.Validate().(*ConfigError).Problems
+	test.Expect(t, len(problems), 3)
+	test.Expect(t, problems[0], "alt_port 3001 must differ from port and app_port")
+	test.Expect(t, problems[1], "blue_green alternates the app between app_port and alt_port, so proxy_to must use app_port 3001")
+	test.Expect(t, problems[2], "blue_green needs a positive ready_timeout to know when the new app is ready")
+}
+
+func Test_Config_Validate_SocketActivation(t *testing.T) {
+	config := validConfig()
+	config.SocketActivation = true
+	test.Expect(t, config.Validate(), nil)
+
+	config.BlueGreen = true
+	config.AltPort = 3002
+	config.ReadyTimeout = Duration(30 * time.Second)
+	config.ProxyTo = "http://example.com:3001"
+
+	problems := config.Validate().(*ConfigError).Problems
+	test.Expect(t, len(problems), 2)
+	test.Expect(t, problems[0], "socket_activation and blue_green cannot be combined, connections already queue on the socket during restarts")
+	test.Expect(t, problems[1], "socket_activation needs proxy_to to be a local address, not example.com")
+}
+
+func Test_Config_Validate_Stop(t *testing.T) {
+	config := validConfig()
+	config.StopSignal = "TERM"
+	config.StopTimeout = Duration(10 * time.Second)
+	config.PreStop = "/shutdown"
+	test.Expect(t, config.Validate(), nil)
+
+	config.StopSignal = "USR1"
+	config.StopTimeout = Duration(-time.Second)
+	config.PreStop = "shutdown"
+
+	problems := config.Validate().(*ConfigError).Problems
+	test.Expect(t, len(problems), 3)
+	test.Expect(t, problems[0], "stop_timeout must not be negative")
+	test.Expect(t, problems[1], "stop_signal: USR1 is not a stop signal, use INT, TERM, QUIT or HUP")
+	test.Expect(t, problems[2], `pre_stop "shutdown" must start with /`)
+}
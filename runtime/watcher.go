@@ -0,0 +1,333 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// WatchAuto uses filesystem notifications, falling back to polling when
+	// they are unavailable or the watch limit is reached
+	WatchAuto = "auto"
+	// WatchNotify uses filesystem notifications
+	WatchNotify = "notify"
+	// WatchPoll periodically compares the modification time and size of files
+	WatchPoll = "poll"
+)
+
+// Watcher reports changes to the files in watched directories
+type Watcher interface {
+	// Add watches the files in a directory
+	Add(dir string) error
+	// Remove stops watching a directory
+	Remove(dir string) error
+	// Events delivers changes to watched files
+	Events() <-chan fsnotify.Event
+	// Errors delivers failures to watch
+	Errors() <-chan error
+	io.Closer
+}
+
+// NewWatcher constructs a new Watcher using the given method
+func NewWatcher(method string, interval time.Duration) (Watcher, error) {
+	events := make(chan fsnotify.Event, 64)
+	errs := make(chan error, 4)
+
+	switch method {
+	case WatchNotify:
+		return newNotifyWatcher(events, errs)
+	case WatchPoll:
+		return newPollingWatcher(interval, events, errs), nil
+	case WatchAuto, "":
+		return newAutoWatcher(interval, events, errs), nil
+	default:
+		return nil, fmt.Errorf("unknown watcher %q", method)
+	}
+}
+
+// watchLimit reports whether err means no more directories can be watched
+// with filesystem notifications
+func watchLimit(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE)
+}
+
+// notifyWatcher watches using filesystem notifications
+type notifyWatcher struct {
+	watcher *fsnotify.Watcher
+	events  chan fsnotify.Event
+	errors  chan error
+}
+
+func newNotifyWatcher(events chan fsnotify.Event, errs chan error) (*notifyWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &notifyWatcher{watcher: watcher, events: events, errors: errs}
+	go w.forward()
+	return w, nil
+}
+
+func (w *notifyWatcher) forward() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.events <- event
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.errors <- err
+		}
+	}
+}
+
+func (w *notifyWatcher) Add(dir string) error {
+	return w.watcher.Add(dir)
+}
+
+func (w *notifyWatcher) Remove(dir string) error {
+	return w.watcher.Remove(dir)
+}
+
+func (w *notifyWatcher) Events() <-chan fsnotify.Event {
+	return w.events
+}
+
+func (w *notifyWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *notifyWatcher) Close() error {
+	return w.watcher.Close()
+}
+
+// pollingWatcher watches by periodically comparing the modification time
+// and size of every file in the watched directories
+type pollingWatcher struct {
+	mu       sync.Mutex
+	interval time.Duration
+	dirs     map[string]map[string]fileStamp
+	events   chan fsnotify.Event
+	errors   chan error
+	done     chan struct{}
+	once     sync.Once
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newPollingWatcher(interval time.Duration, events chan fsnotify.Event, errs chan error) *pollingWatcher {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	w := &pollingWatcher{
+		interval: interval,
+		dirs:     make(map[string]map[string]fileStamp),
+		events:   events,
+		errors:   errs,
+		done:     make(chan struct{}),
+	}
+	go w.poll()
+	return w
+}
+
+func (w *pollingWatcher) Add(dir string) error {
+	stamps, err := stat(dir)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.dirs[dir]; !ok {
+		w.dirs[dir] = stamps
+	}
+	return nil
+}
+
+func (w *pollingWatcher) Remove(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.dirs, dir)
+	return nil
+}
+
+func (w *pollingWatcher) Events() <-chan fsnotify.Event {
+	return w.events
+}
+
+func (w *pollingWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *pollingWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+func (w *pollingWatcher) poll() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		// events are sent without holding the lock, as receivers commonly
+		// add directories in response
+		for _, event := range w.scan() {
+			select {
+			case w.events <- event:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// scan compares every watched directory with its last snapshot
+func (w *pollingWatcher) scan() []fsnotify.Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []fsnotify.Event
+	for dir, previous := range w.dirs {
+		current, err := stat(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				delete(w.dirs, dir)
+				events = append(events, fsnotify.Event{Name: dir, Op: fsnotify.Remove})
+			}
+			continue
+		}
+
+		for name, stamp := range current {
+			before, ok := previous[name]
+			switch {
+			case !ok:
+				events = append(events, fsnotify.Event{Name: name, Op: fsnotify.Create})
+			case !stamp.modTime.Equal(before.modTime) || stamp.size != before.size:
+				events = append(events, fsnotify.Event{Name: name, Op: fsnotify.Write})
+			}
+		}
+		for name := range previous {
+			if _, ok := current[name]; !ok {
+				events = append(events, fsnotify.Event{Name: name, Op: fsnotify.Remove})
+			}
+		}
+		w.dirs[dir] = current
+	}
+
+	return events
+}
+
+func stat(dir string) (map[string]fileStamp, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	stamps := make(map[string]fileStamp, len(infos))
+	for _, info := range infos {
+		stamps[filepath.Join(dir, info.Name())] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps, nil
+}
+
+// autoWatcher uses filesystem notifications until they fail, then polls
+type autoWatcher struct {
+	mu       sync.Mutex
+	current  Watcher
+	polling  bool
+	dirs     map[string]struct{}
+	interval time.Duration
+	events   chan fsnotify.Event
+	errors   chan error
+}
+
+func newAutoWatcher(interval time.Duration, events chan fsnotify.Event, errs chan error) *autoWatcher {
+	w := &autoWatcher{
+		dirs:     make(map[string]struct{}),
+		interval: interval,
+		events:   events,
+		errors:   errs,
+	}
+
+	notify, err := newNotifyWatcher(events, errs)
+	if err != nil {
+		log.Printf("filesystem notifications unavailable (%v), polling for changes", err)
+		w.poll()
+	} else {
+		w.current = notify
+	}
+	return w
+}
+
+func (w *autoWatcher) Add(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.current.Add(dir)
+	if err != nil && !w.polling && watchLimit(err) {
+		log.Printf("filesystem notification limit reached (%v), polling for changes", err)
+		w.current.Close()
+		w.poll()
+		for watched := range w.dirs {
+			w.current.Add(watched)
+		}
+		err = w.current.Add(dir)
+	}
+
+	if err == nil {
+		w.dirs[dir] = struct{}{}
+	}
+	return err
+}
+
+// poll switches to a polling watcher; the caller must hold the lock
+func (w *autoWatcher) poll() {
+	w.current = newPollingWatcher(w.interval, w.events, w.errors)
+	w.polling = true
+}
+
+func (w *autoWatcher) Remove(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.dirs, dir)
+	return w.current.Remove(dir)
+}
+
+func (w *autoWatcher) Events() <-chan fsnotify.Event {
+	return w.events
+}
+
+func (w *autoWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *autoWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current.Close()
+}
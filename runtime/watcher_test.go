@@ -0,0 +1,50 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/n3integration/reload/test"
+)
+
+func Test_NewWatcher_Unknown(t *testing.T) {
+	_, err := NewWatcher("magic", time.Second)
+	test.Refute(t, err, nil)
+}
+
+func Test_PollingWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	watcher, err := NewWatcher(WatchPoll, 10*time.Millisecond)
+	test.Expect(t, err, nil)
+	defer watcher.Close()
+
+	test.Expect(t, watcher.Add(dir), nil)
+
+	file := filepath.Join(dir, "main.go")
+	ioutil.WriteFile(file, []byte("package main\n"), 0644)
+	expectEvent(t, watcher, fsnotify.Event{Name: file, Op: fsnotify.Create})
+
+	ioutil.WriteFile(file, []byte("package main\n\nfunc main() {}\n"), 0644)
+	expectEvent(t, watcher, fsnotify.Event{Name: file, Op: fsnotify.Write})
+
+	os.Remove(file)
+	expectEvent(t, watcher, fsnotify.Event{Name: file, Op: fsnotify.Remove})
+}
+
+func expectEvent(t *testing.T, watcher Watcher, expected fsnotify.Event) {
+	select {
+	case event := <-watcher.Events():
+		test.Expect(t, event, expected)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for %v", expected)
+	}
+}
@@ -2,12 +2,16 @@ package test
 
 import (
 	"io"
+	"net/url"
 	"os"
 	"os/exec"
+	"time"
 )
 
 type MockRunner struct {
-	DidRun bool
+	DidRun     bool
+	MockOutput string
+	MockState  *os.ProcessState
 }
 
 func NewMockRunner() *MockRunner {
@@ -28,26 +32,28 @@ func (m *MockRunner) Info() (os.FileInfo, error) {
 func (m *MockRunner) SetWriter(io.Writer) {
 }
 
-func (m *MockRunner) Kill() error {
-	return nil
+func (m *MockRunner) SetListener(*os.File) {
+}
+
+func (m *MockRunner) SetStop(*url.URL, os.Signal, time.Duration) {
 }
 
-type MockBuilder struct {
-	MockErrors string
+func (m *MockRunner) Output() string {
+	return m.MockOutput
 }
 
-func NewMockBuilder() *MockBuilder {
-	return &MockBuilder{}
+func (m *MockRunner) ProcessState() *os.ProcessState {
+	return m.MockState
 }
 
-func (m *MockBuilder) Binary() string {
-	return "bin"
+func (m *MockRunner) Kill() error {
+	return nil
 }
 
-func (m *MockBuilder) Build() error {
+func (m *MockRunner) Signal(sig os.Signal) error {
 	return nil
 }
 
-func (m *MockBuilder) Errors() string {
-	return m.MockErrors
+func (m *MockRunner) Replace(port int, ready func(*exec.Cmd, <-chan struct{}) error) error {
+	return ready(nil, make(chan struct{}))
 }